@@ -2,7 +2,8 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
@@ -12,77 +13,239 @@ import (
 	"L0_project/internal/cache"
 	"L0_project/internal/config"
 	"L0_project/internal/database"
+	"L0_project/internal/health"
+	"L0_project/internal/ingest"
 	"L0_project/internal/kafka"
+	"L0_project/internal/logger"
+	"L0_project/internal/metrics"
+	"L0_project/internal/model"
+	"L0_project/internal/rules"
+	"L0_project/migrations"
+
+	"github.com/redis/go-redis/v9"
 )
 
 func main() {
 	cfg := config.Get()
+	log := logger.New(cfg.Log.Level)
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(cfg, log, os.Args[2:]))
+	}
 
-	db, err := database.New(cfg.Postgres.URL)
+	db, err := database.New(cfg.Postgres.URL, log)
 	if err != nil {
-		log.Fatalf("не удалось подключиться к postgres: %v", err)
+		log.Error("не удалось подключиться к postgres", "error", err)
+		os.Exit(1)
 	}
 	defer db.Close()
 
-	// Миграции теперь выполняются вне кода (см. migrations/).
-
-	orderCache := cache.NewLRUCache(cfg.Cache.Size)
+	// Реплики, запущенные одновременно, применяют миграции по очереди под advisory-блокировкой.
+	if cfg.Postgres.MigrateOnStart {
+		migrator, err := database.NewMigrator(db, migrations.FS, database.MigratorConfig{}, log)
+		if err == nil {
+			_, err = migrator.Up(context.Background(), 0)
+		}
+		if err != nil {
+			log.Error("не удалось применить миграции", "error", err)
+			os.Exit(1)
+		}
+	}
 
-	log.Println("Подготовка кеша...")
-	orders, err := db.GetAllOrders(context.Background())
+	localCache, err := cache.New(cfg.Cache.Impl, cache.Config{
+		Capacity: cfg.Cache.Size,
+		TTL:      cfg.Cache.TTL,
+		MaxBytes: cfg.Cache.MaxBytes,
+		Shards:   cfg.Cache.Shards,
+		OnEvict: func(key string, _ *model.Order, reason cache.EvictReason) {
+			log.Debug("заказ удалён из кэша", "order_uid", key, "reason", reason.String())
+		},
+	})
 	if err != nil {
-		log.Printf("не удалось получить все заказы для инициализации кеша: %v", err)
-	} else {
-		for _, order := range orders {
-			orderCopy := order
-			orderCache.Add(order.OrderUID, &orderCopy)
-		}
-		log.Printf("Кеш прогрет. Загружено %d заказов.", len(orders))
+		log.Error("не удалось создать кэш", "error", err)
+		os.Exit(1)
 	}
+	orderCache := localCache
+
+	var redisClient *redis.Client
+	if cfg.Cache.Backend == "redis" {
+		redisClient = redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+			// Таймауты обращений задаёт контекст с REDIS_TIMEOUT, а не ReadTimeout клиента.
+			ContextTimeoutEnabled: true,
+		})
+		defer redisClient.Close()
+
+		// Локальный кэш становится L1 перед общим кэшем в Redis.
+		orderCache = cache.NewRedisCache(redisClient, localCache, cache.RedisConfig{
+			Prefix:  cfg.Redis.KeyPrefix,
+			TTL:     cfg.Redis.TTL,
+			Timeout: cfg.Redis.Timeout,
+		}, log)
+	}
+	metrics.RegisterCache(orderCache)
 
 	ctx, cancel := context.WithCancel(context.Background())
-	consumer := kafka.NewConsumer(cfg.Kafka.Brokers, cfg.Kafka.Topic, cfg.Kafka.GroupID, db, orderCache)
-	go consumer.Start(ctx)
 
-	handler := api.NewHandler(db, orderCache)
-	router := api.NewRouter(handler)
+	// Прогрев кэша идёт в фоне: HTTP-сервер стартует сразу, но /readyz
+	// не сообщает о готовности, пока кэш не заполнен.
+	warmedUp := health.NewFlag(errors.New("прогрев кэша не завершён"))
+	if redisClient != nil {
+		// Общий кэш в Redis заполняется consumer и запросами всех реплик,
+		// поэтому каждая реплика не загружает его из Postgres заново.
+		warmedUp.Set()
+	} else {
+		go warmUpCache(ctx, db, orderCache, warmedUp, log)
+	}
+
+	// Другие реплики изменяют заказы в базе; уведомления об этом удаляют
+	// устаревшие записи из локального кэша этой реплики. Общий кэш в Redis
+	// обновляет сама реплика, изменившая заказ, поэтому его уведомления не трогают:
+	// иначе каждое изменение удаляло бы ключ в Redis столько раз, сколько реплик.
+	changes, err := database.NewChangeListener(cfg.Postgres.URL, log)
+	if err != nil {
+		log.Error("не удалось подписаться на изменения заказов", "error", err)
+		os.Exit(1)
+	}
+	go changes.Run(ctx, localCache.Remove, localCache.Purge)
+
+	orderRules, err := rules.New(rules.Config{
+		Disabled:     cfg.Rules.Disabled,
+		Enabled:      cfg.Rules.Enabled,
+		MaxClockSkew: cfg.Rules.MaxClockSkew,
+	})
+	if err != nil {
+		log.Error("некорректная настройка бизнес-правил", "error", err)
+		os.Exit(1)
+	}
+	log.Info("бизнес-правила включены", "rules", orderRules.Names())
+
+	// Заказы из Kafka и из HTTP API принимаются одним сервисом.
+	orderIngest := ingest.NewService(db, orderCache, orderRules)
+
+	consumer := kafka.NewConsumer(kafka.ConsumerConfig{
+		Brokers:         cfg.Kafka.Brokers,
+		Topic:           cfg.Kafka.Topic,
+		GroupID:         cfg.Kafka.GroupID,
+		DeadLetterTopic: cfg.Kafka.DLQTopic,
+		FetchStaleness:  cfg.Kafka.FetchStaleness,
+		BatchSize:       cfg.Kafka.BatchSize,
+		BatchTimeout:    cfg.Kafka.BatchTimeout,
+		Workers:         cfg.Kafka.Workers,
+		DrainTimeout:    cfg.Kafka.DrainTimeout,
+		Retry: kafka.RetryPolicy{
+			MaxAttempts:    cfg.Kafka.Retry.MaxAttempts,
+			InitialBackoff: cfg.Kafka.Retry.InitialBackoff,
+			MaxBackoff:     cfg.Kafka.Retry.MaxBackoff,
+		},
+	}, db, orderCache, orderIngest, log)
+	consumerDone := make(chan struct{})
+	go func() {
+		defer close(consumerDone)
+		consumer.Start(ctx)
+	}()
+
+	relay := kafka.NewOutboxRelay(kafka.OutboxRelayConfig{
+		Brokers:      cfg.Kafka.Brokers,
+		Topic:        cfg.Kafka.Outbox.Topic,
+		BatchSize:    cfg.Kafka.Outbox.BatchSize,
+		PollInterval: cfg.Kafka.Outbox.PollInterval,
+		Retry: kafka.RetryPolicy{
+			InitialBackoff: cfg.Kafka.Retry.InitialBackoff,
+			MaxBackoff:     cfg.Kafka.Retry.MaxBackoff,
+		},
+	}, db, log)
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		relay.Run(ctx)
+	}()
+
+	checker := health.New(cfg.Health.Timeout)
+	checker.Add("postgres", db.Ping)
+	checker.Add("kafka", consumer.Ready)
+	checker.Add("cache_warmup", warmedUp.Check)
+	if redisClient != nil {
+		checker.Add("redis", func(ctx context.Context) error {
+			return redisClient.Ping(ctx).Err()
+		})
+	}
+
+	handler := api.NewHandler(db, orderCache, orderIngest, log)
+	router := api.NewRouter(handler, checker, log)
 
 	srv := api.NewServer(cfg.HTTP.Port, router)
 
 	// Запускаем HTTP сервер в отдельной горутине
-	
+
 	serverErr := make(chan error, 1)
 	go func() {
+		log.Info("HTTP сервер запущен", "addr", srv.Addr)
 		serverErr <- srv.ListenAndServe()
 	}()
 
 	// Ожидаем сигнал завершения или ошибку сервера
-	
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
 	select {
 	case <-quit:
-		log.Println("Получен сигнал завершения")
+		log.Info("получен сигнал завершения")
 	case err := <-serverErr:
 		if err != nil {
-			log.Printf("HTTP сервер завершился с ошибкой: %v", err)
+			log.Error("HTTP сервер завершился с ошибкой", "error", err)
 		}
 	}
 
-	log.Println("Завершение работы приложения...")
+	log.Info("завершение работы приложения")
 
 	// Сигнал для остановки consumer
-	
+
 	cancel()
-	
+
 	// Плавно останавливаем HTTP сервер
-	
+
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer shutdownCancel()
 	if err := srv.Shutdown(shutdownCtx); err != nil {
-		log.Printf("Ошибка при остановке сервера: %v", err)
+		log.Error("ошибка при остановке сервера", "error", err)
 	}
 
-	log.Println("Приложение было успешно остановлено.")
+	// Ждём, пока consumer дообработает полученные сообщения и подтвердит offset.
+	<-consumerDone
+	<-relayDone
+
+	log.Info("приложение остановлено")
+}
+
+// warmUpCache загружает заказы из базы в кэш, повторяя попытки до успеха
+// или остановки приложения, и отмечает флаг готовности.
+func warmUpCache(ctx context.Context, db *database.Storage, orderCache cache.OrderCache, done *health.Flag, log *slog.Logger) {
+	delay := time.Second
+	for {
+		log.Info("подготовка кеша")
+		orders, err := db.GetAllOrders(ctx)
+		if err == nil {
+			for _, order := range orders {
+				orderCopy := order
+				orderCache.Add(order.OrderUID, &orderCopy)
+			}
+			done.Set()
+			log.Info("кеш прогрет", "orders", len(orders))
+			return
+		}
+
+		log.Error("не удалось получить все заказы для инициализации кеша", "error", err, "retry_in", delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay < 30*time.Second {
+			delay *= 2
+		}
+	}
 }
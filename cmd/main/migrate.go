@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+
+	"L0_project/internal/config"
+	"L0_project/internal/database"
+	"L0_project/migrations"
+)
+
+const migrateUsage = `Использование: main migrate [-dry-run] <команда>
+
+Команды:
+  up [N]       применить N следующих миграций, без N — все
+  down [N]     откатить N последних миграций, без N — одну
+  goto V       применить или откатить миграции до версии V (0 — откатить все)
+  force V      записать версию V без выполнения миграций и снять флаг dirty
+  status       показать версию базы и список миграций
+
+Флаги:
+`
+
+// runMigrate выполняет подкоманду migrate и возвращает код завершения.
+func runMigrate(cfg *config.Config, log *slog.Logger, args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "показать шаги и SQL, не изменяя базу")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), migrateUsage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	command, arg := flags.Arg(0), flags.Arg(1)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	db, err := database.New(cfg.Postgres.URL, log)
+	if err != nil {
+		log.Error("не удалось подключиться к postgres", "error", err)
+		return 1
+	}
+	defer db.Close()
+
+	m, err := database.NewMigrator(db, migrations.FS, database.MigratorConfig{DryRun: *dryRun}, log)
+	if err != nil {
+		log.Error("не удалось прочитать миграции", "error", err)
+		return 1
+	}
+
+	var steps []database.MigrationStep
+	switch command {
+	case "up", "down":
+		n := 0
+		if command == "down" {
+			n = 1
+		}
+		if arg != "" {
+			if n, err = strconv.Atoi(arg); err != nil || n <= 0 {
+				fmt.Fprintf(os.Stderr, "число миграций должно быть положительным: %q\n", arg)
+				return 2
+			}
+		}
+		if command == "up" {
+			steps, err = m.Up(ctx, n)
+		} else {
+			steps, err = m.Down(ctx, n)
+		}
+	case "goto", "force":
+		version, perr := strconv.ParseInt(arg, 10, 64)
+		if perr != nil || version < 0 {
+			fmt.Fprintf(os.Stderr, "нужна версия миграции: %q\n", arg)
+			return 2
+		}
+		if command == "goto" {
+			steps, err = m.Goto(ctx, version)
+		} else {
+			err = m.Force(ctx, version)
+		}
+	case "status":
+		err = printMigrationStatus(ctx, m)
+	default:
+		flags.Usage()
+		return 2
+	}
+
+	if *dryRun {
+		for _, step := range steps {
+			fmt.Printf("-- %s %d_%s (версия после шага: %d)\n%s\n", step.Direction, step.Version, step.Name, step.To, step.SQL())
+		}
+	}
+	if err != nil {
+		if errors.Is(err, database.ErrDirty) {
+			log.Error("миграция прервана, исправьте схему и выполните migrate force <версия>", "error", err)
+		} else {
+			log.Error("ошибка миграции", "command", command, "error", err)
+		}
+		return 1
+	}
+	return 0
+}
+
+func printMigrationStatus(ctx context.Context, m *database.Migrator) error {
+	status, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Версия базы: %d", status.Version)
+	if status.Dirty {
+		fmt.Print(" (dirty)")
+	}
+	fmt.Printf(", последняя миграция: %d\n\n", m.Latest())
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ВЕРСИЯ\tИМЯ\tСОСТОЯНИЕ")
+	for _, mg := range status.Migrations {
+		state := "ожидает"
+		if mg.Version <= status.Version {
+			state = "применена"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", mg.Version, mg.Name, state)
+	}
+	return w.Flush()
+}
@@ -4,49 +4,70 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"regexp"
+	"strconv"
 	"time"
 
 	"github.com/brianvoe/gofakeit/v7"
 	"github.com/google/uuid"
 	"github.com/segmentio/kafka-go"
 
+	"L0_project/internal/codec"
+	internalkafka "L0_project/internal/kafka"
+	"L0_project/internal/logger"
 	"L0_project/internal/model"
+	"L0_project/internal/rules"
+	"L0_project/internal/schema"
 )
 
 func main() {
 	topic := "orders"
 	brokerAddress := "localhost:9092"
+	log := logger.New(os.Getenv("LOG_LEVEL")).With("component", "producer", "topic", topic)
 
+	// Ключ сообщения — order_uid, а Hash направляет все события одного заказа
+	// в одну партицию: событие статуса не обгонит сам заказ.
 	w := &kafka.Writer{
 		Addr:     kafka.TCP(brokerAddress),
 		Topic:    topic,
-		Balancer: &kafka.LeastBytes{},
+		Balancer: &kafka.Hash{},
 	}
 	defer func() {
 		if err := w.Close(); err != nil {
-			log.Printf("ошибка при закрытии писателя Kafka: %v", err)
+			log.Error("ошибка при закрытии писателя Kafka", "error", err)
 		}
 	}()
 
 	byteValue, err := os.ReadFile("./model.json")
 	if err != nil {
-		log.Printf("внимание: не удалось прочитать model.json (будет использоваться fake режим): %v", err)
+		log.Warn("не удалось прочитать model.json, будет использоваться fake режим", "error", err)
 	}
 
 	var baseOrder model.Order
 	if len(byteValue) > 0 {
 		if err := json.Unmarshal(byteValue, &baseOrder); err != nil {
-			log.Printf("внимание: не удалось разобрать model.json: %v", err)
+			log.Warn("не удалось разобрать model.json", "error", err)
 		}
 	}
 
-	fmt.Println("Продюсер запущен. Нажмите CTRL+C для остановки.")
+	log.Info("продюсер запущен, нажмите CTRL+C для остановки")
 	gofakeit.Seed(time.Now().UnixNano())
 
 	mode := os.Getenv("PRODUCER_MODE") // "json" или "fake" (default)
+	// PRODUCER_FORMAT — формат сообщений: json (по умолчанию), protobuf или avro.
+	format := codec.JSON
+	if name := os.Getenv("PRODUCER_FORMAT"); name != "" {
+		if format, err = codec.ByName(name); err != nil {
+			log.Error("некорректный формат сообщений", "error", err)
+			os.Exit(1)
+		}
+	}
+	log.Info("формат сообщений", "format", format.Name())
+	// PRODUCER_STATUS_EVENTS=true — после каждого заказа продвигать статус одного из отправленных заказов.
+	statusEvents := os.Getenv("PRODUCER_STATUS_EVENTS") == "true"
+	statuses := make(map[string]model.OrderStatus)
 	for {
 		var order model.Order
 		if mode == "json" && baseOrder.OrderUID != "" {
@@ -66,9 +87,9 @@ func main() {
 		} else {
 			order = generateRandomOrder()
 		}
-		orderBytes, err := json.Marshal(order)
+		orderBytes, headers, err := encodeOrder(format, &order)
 		if err != nil {
-			log.Printf("не удалось преобразовать заказ в JSON: %v", err)
+			log.Error("не удалось сериализовать заказ", "format", format.Name(), "order_uid", order.OrderUID, "error", err)
 			time.Sleep(1 * time.Second)
 			continue
 		}
@@ -78,21 +99,54 @@ func main() {
 			orderUID = uuid.New().String()
 		}
 
-		if err := w.WriteMessages(context.Background(), kafka.Message{Key: []byte(orderUID), Value: orderBytes}); err != nil {
-			log.Printf("ошибка отправки сообщения в Kafka: %v", err)
+		if err := w.WriteMessages(context.Background(), kafka.Message{
+			Key:     []byte(orderUID),
+			Value:   orderBytes,
+			Headers: headers,
+		}); err != nil {
+			log.Error("ошибка отправки сообщения в Kafka", "order_uid", orderUID, "error", err)
 			time.Sleep(1 * time.Second)
 			continue
 		}
 
-		fmt.Printf("Отправлен заказ с UID: %s\n", orderUID)
+		log.Info("заказ отправлен", "order_uid", orderUID)
+
+		if statusEvents {
+			statuses[orderUID] = model.StatusCreated
+			sendStatusEvent(w, statuses, log)
+		}
 		time.Sleep(2 * time.Second)
 	}
 }
 
+// generateRandomOrder создает заказ, который проходит бизнес-правила consumer
+// (см. internal/rules): суммы согласованы, трек-номера и транзакция совпадают с заказом.
 func generateRandomOrder() model.Order {
 	orderUID := uuid.New().String()
 	trackNumber := fmt.Sprintf("WBILM%d", gofakeit.Number(1000000, 9999999))
 
+	items := make([]model.Item, gofakeit.Number(1, 3))
+	goodsTotal := 0
+	for i := range items {
+		price := gofakeit.Number(50, 2000)
+		sale := gofakeit.Number(0, 50)
+		items[i] = model.Item{
+			ChrtID:      gofakeit.Number(100000, 999999),
+			TrackNumber: trackNumber,
+			Price:       price,
+			Rid:         uuid.New().String(),
+			Name:        gofakeit.ProductName(),
+			Sale:        sale,
+			Size:        "0",
+			TotalPrice:  rules.ItemTotalPrice(price, sale),
+			NmID:        gofakeit.Number(1000000, 9999999),
+			Brand:       gofakeit.Company(),
+			Status:      202,
+		}
+		goodsTotal += items[i].TotalPrice
+	}
+	deliveryCost := gofakeit.Number(100, 1500)
+
 	return model.Order{
 		OrderUID:    orderUID,
 		TrackNumber: trackNumber,
@@ -110,33 +164,71 @@ func generateRandomOrder() model.Order {
 			Transaction:  orderUID,
 			Currency:     "USD",
 			Provider:     "wbpay",
-			Amount:       gofakeit.Number(100, 5000),
+			Amount:       goodsTotal + deliveryCost,
 			PaymentDt:    time.Now().Unix(),
 			Bank:         "alpha",
-			DeliveryCost: gofakeit.Number(100, 1500),
-			GoodsTotal:   gofakeit.Number(1, 10),
+			DeliveryCost: deliveryCost,
+			GoodsTotal:   goodsTotal,
 			CustomFee:    0,
 		},
-		Items: []model.Item{
-			{
-				ChrtID:      gofakeit.Number(100000, 999999),
-				TrackNumber: trackNumber,
-				Price:       gofakeit.Number(50, 2000),
-				Name:        gofakeit.ProductName(),
-				Sale:        gofakeit.Number(0, 50),
-				Size:        "0",
-				TotalPrice:  gofakeit.Number(50, 2000),
-				NmID:        gofakeit.Number(1000000, 9999999),
-				Brand:       gofakeit.Company(),
-				Status:      202,
-			},
-		},
+		Items:       items,
 		Locale:      "en",
 		CustomerID:  gofakeit.Username(),
 		DateCreated: time.Now(),
 	}
 }
 
+// nextStatus выбирает следующий статус заказа: обычно по основному пути
+// до доставки, иногда отмену или возврат.
+func nextStatus(s model.OrderStatus) (model.OrderStatus, bool) {
+	rare := gofakeit.Number(1, 10) == 1
+	switch {
+	case rare && (s == model.StatusCreated || s == model.StatusPaid || s == model.StatusAssembling):
+		return model.StatusCancelled, true
+	case s == model.StatusCreated:
+		return model.StatusPaid, true
+	case s == model.StatusPaid:
+		return model.StatusAssembling, true
+	case s == model.StatusAssembling:
+		return model.StatusShipped, true
+	case s == model.StatusShipped:
+		return model.StatusDelivered, true
+	case rare && s == model.StatusDelivered:
+		return model.StatusReturned, true
+	}
+	return "", false
+}
+
+// sendStatusEvent отправляет событие смены статуса для случайного из отправленных
+// заказов. Заказы в конечном статусе больше не отслеживаются.
+func sendStatusEvent(w *kafka.Writer, statuses map[string]model.OrderStatus, log *slog.Logger) {
+	for orderUID, current := range statuses {
+		next, ok := nextStatus(current)
+		if !ok {
+			delete(statuses, orderUID)
+			return
+		}
+
+		value, err := json.Marshal(internalkafka.StatusEvent{OrderUID: orderUID, Status: next})
+		if err != nil {
+			log.Error("не удалось преобразовать событие статуса в JSON", "order_uid", orderUID, "error", err)
+			return
+		}
+		err = w.WriteMessages(context.Background(), kafka.Message{
+			Key:     []byte(orderUID),
+			Value:   value,
+			Headers: []kafka.Header{{Key: internalkafka.HeaderEventType, Value: []byte(internalkafka.EventOrderStatus)}},
+		})
+		if err != nil {
+			log.Error("ошибка отправки события статуса в Kafka", "order_uid", orderUID, "error", err)
+			return
+		}
+		statuses[orderUID] = next
+		log.Info("событие статуса отправлено", "order_uid", orderUID, "status", next)
+		return
+	}
+}
+
 // generateE164Phone создает псевдо-реалистичный номер телефона в формате E.164.
 // Простая реализация: префикс +7 и 10 цифр. Можно улучшить при необходимости.
 func generateE164Phone() string {
@@ -170,3 +262,17 @@ func ensureE164(phone string) string {
 	// иначе просто возвращаем '+' + digits
 	return "+" + digits
 }
+
+// encodeOrder сериализует заказ в выбранном формате и возвращает заголовки с форматом.
+// JSON публикуется в текущей версии схемы, версия передаётся в заголовке.
+func encodeOrder(format codec.Codec, order *model.Order) ([]byte, []kafka.Header, error) {
+	headers := []kafka.Header{{Key: internalkafka.HeaderContentType, Value: []byte(format.ContentType())}}
+	if format != codec.JSON {
+		value, err := format.Marshal(order)
+		return value, headers, err
+	}
+
+	value, err := schema.Encode(order)
+	headers = append(headers, kafka.Header{Key: internalkafka.HeaderSchemaVersion, Value: []byte(strconv.Itoa(schema.Current))})
+	return value, headers, err
+}
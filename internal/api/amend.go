@@ -0,0 +1,235 @@
+package api
+
+import (
+	"L0_project/internal/database"
+	"L0_project/internal/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxActorLen — длина колонки order_audit.actor.
+const maxActorLen = 255
+
+// errItemNotFound возвращается, если в заказе нет товара с указанным rid.
+var errItemNotFound = errors.New("товар не найден в заказе")
+
+// deliveryPatch — изменяемые поля доставки. Поля, которых нет в запросе, не меняются.
+type deliveryPatch struct {
+	Name    *string `json:"name"`
+	Phone   *string `json:"phone"`
+	Zip     *string `json:"zip"`
+	City    *string `json:"city"`
+	Address *string `json:"address"`
+	Region  *string `json:"region"`
+	Email   *string `json:"email"`
+}
+
+// itemPatch — изменяемые поля товара.
+type itemPatch struct {
+	Size *string `json:"size"`
+}
+
+// AmendDelivery изменяет данные доставки заказа (PATCH с частью полей).
+// Доставку можно изменить, пока заказ не передан в доставку.
+func (h *Handler) AmendDelivery(w http.ResponseWriter, r *http.Request) {
+	var patch deliveryPatch
+	if !decodeBody(w, r, &patch, false) {
+		return
+	}
+
+	h.amendOrder(w, r, database.Amendment{
+		Action: model.AuditAmendDelivery,
+		Apply: func(order *model.Order) error {
+			if !order.Status.CanAmendDelivery() {
+				return fmt.Errorf("%w: доставку заказа в статусе %s", model.ErrNotAmendable, order.Status)
+			}
+			d := &order.Delivery
+			for _, f := range []struct {
+				dst *string
+				src *string
+			}{
+				{&d.Name, patch.Name}, {&d.Phone, patch.Phone}, {&d.Zip, patch.Zip}, {&d.City, patch.City},
+				{&d.Address, patch.Address}, {&d.Region, patch.Region}, {&d.Email, patch.Email},
+			} {
+				if f.src != nil {
+					*f.dst = *f.src
+				}
+			}
+			return h.ingest.Validate(order)
+		},
+	})
+}
+
+// CancelOrder отменяет заказ: {"reason": "..."}, тело необязательно.
+// Отменить можно только заказ, который ещё не передан в доставку; повторная
+// отмена ничего не меняет.
+func (h *Handler) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if !decodeBody(w, r, &req, true) {
+		return
+	}
+
+	h.amendOrder(w, r, database.Amendment{
+		Action: model.AuditCancel,
+		Reason: req.Reason,
+		Apply: func(order *model.Order) error {
+			order.Status = model.StatusCancelled
+			return nil
+		},
+	})
+}
+
+// AmendItem изменяет товар заказа с идентификатором rid. Состав заказа можно
+// менять только до оплаты.
+func (h *Handler) AmendItem(w http.ResponseWriter, r *http.Request) {
+	var patch itemPatch
+	if !decodeBody(w, r, &patch, false) {
+		return
+	}
+
+	h.amendItems(w, r, model.AuditAmendItem, func(items []model.Item, i int) []model.Item {
+		if patch.Size != nil {
+			items[i].Size = *patch.Size
+		}
+		return items
+	})
+}
+
+// RemoveItem удаляет товар rid из заказа и пересчитывает суммы оплаты.
+// Удалить последний товар нельзя: заказ без товаров не проходит проверку.
+func (h *Handler) RemoveItem(w http.ResponseWriter, r *http.Request) {
+	h.amendItems(w, r, model.AuditRemoveItem, func(items []model.Item, i int) []model.Item {
+		return slices.Delete(items, i, i+1)
+	})
+}
+
+// amendItems применяет change к товару из параметра rid, пересчитывает
+// payment.goods_total и payment.amount и проверяет заказ так же, как при приёме.
+func (h *Handler) amendItems(w http.ResponseWriter, r *http.Request, action string, change func(items []model.Item, i int) []model.Item) {
+	rid := chi.URLParam(r, "rid")
+
+	h.amendOrder(w, r, database.Amendment{
+		Action: action,
+		Apply: func(order *model.Order) error {
+			if !order.Status.CanAmendItems() {
+				return fmt.Errorf("%w: состав заказа в статусе %s", model.ErrNotAmendable, order.Status)
+			}
+			i := slices.IndexFunc(order.Items, func(item model.Item) bool { return item.Rid == rid })
+			if i < 0 {
+				return fmt.Errorf("%w: rid %s", errItemNotFound, rid)
+			}
+
+			before := order.Payment.GoodsTotal
+			order.Items = change(order.Items, i)
+			order.Payment.GoodsTotal = 0
+			for _, item := range order.Items {
+				order.Payment.GoodsTotal += item.TotalPrice
+			}
+			order.Payment.Amount += order.Payment.GoodsTotal - before
+			return h.ingest.Validate(order)
+		},
+	})
+}
+
+// GetOrderAudit возвращает журнал изменений заказа через API.
+func (h *Handler) GetOrderAudit(w http.ResponseWriter, r *http.Request) {
+	orderUID := chi.URLParam(r, "orderUID")
+
+	entries, err := h.db.GetOrderAudit(r.Context(), orderUID)
+	if err != nil {
+		writeStorageError(w, r, h.logger(r).With("order_uid", orderUID), err, "Не удалось получить журнал изменений заказа")
+		return
+	}
+	if entries == nil {
+		entries = []model.AuditEntry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// amendOrder применяет change к заказу из параметра orderUID. Версия заказа
+// берётся из обязательного заголовка If-Match (ETag из GET /api/order/{orderUID}),
+// автор изменения — из X-Actor. Изменённый заказ кладётся в кэш и возвращается
+// с новым ETag.
+func (h *Handler) amendOrder(w http.ResponseWriter, r *http.Request, change database.Amendment) {
+	orderUID := chi.URLParam(r, "orderUID")
+	log := h.logger(r).With("order_uid", orderUID, "action", change.Action)
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		writeProblem(w, r, http.StatusPreconditionRequired, CodePreconditionRequired, "Нужен заголовок If-Match с ETag заказа")
+		return
+	}
+	version, ok := parseETag(ifMatch)
+	if !ok {
+		writeProblem(w, r, http.StatusPreconditionFailed, CodeVersionMismatch, "If-Match не совпадает с версией заказа")
+		return
+	}
+
+	change.Actor = r.Header.Get("X-Actor")
+	if change.Actor == "" {
+		change.Actor = model.StatusSourceAPI
+	}
+	if len(change.Actor) > maxActorLen {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "X-Actor не может быть длиннее "+strconv.Itoa(maxActorLen)+" символов")
+		return
+	}
+	log = log.With("actor", change.Actor)
+
+	order, err := h.db.AmendOrder(r.Context(), orderUID, version, change)
+	if err != nil {
+		if errors.Is(err, errItemNotFound) {
+			writeProblem(w, r, http.StatusNotFound, CodeItemNotFound, err.Error())
+			return
+		}
+		writeStorageError(w, r, log, err, "Не удалось изменить заказ")
+		return
+	}
+	log.Info("заказ изменён через API", "version", order.Version)
+
+	h.cache.Add(order.OrderUID, order)
+	h.writeOrder(w, r, http.StatusOK, order)
+}
+
+// etag возвращает ETag заказа версии version.
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// parseETag разбирает ETag, выданный etag. Слабые ETag (W/"...") не принимаются:
+// If-Match требует строгого сравнения.
+func parseETag(s string) (int, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return 0, false
+	}
+	version, err := strconv.Atoi(s[1 : len(s)-1])
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+// decodeBody разбирает JSON-тело запроса в v. Неизвестные поля — ошибка: так
+// опечатка в имени поля не превращается в пустое изменение. Если optional,
+// пустое тело допустимо. При ошибке ответ уже записан.
+func decodeBody(w http.ResponseWriter, r *http.Request, v any, optional bool) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxOrderBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil && !(optional && errors.Is(err, io.EOF)) {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Некорректное тело запроса: "+err.Error())
+		return false
+	}
+	return true
+}
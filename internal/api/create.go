@@ -0,0 +1,86 @@
+package api
+
+import (
+	"L0_project/internal/codec"
+	"L0_project/internal/database"
+	"L0_project/internal/schema"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// maxOrderBodySize ограничивает размер тела POST /api/orders.
+const maxOrderBodySize = 1 << 20
+
+// maxIdempotencyKeyLen — длина колонки idempotency_keys.key.
+const maxIdempotencyKeyLen = 255
+
+// CreateOrder принимает заказ от партнёров без доступа к Kafka. Разбор, проверка
+// и сохранение те же, что у consumer (см. пакет ingest). Формат тела задаёт
+// Content-Type (JSON по умолчанию), версию JSON-схемы — X-Schema-Version.
+// Созданный заказ возвращается с кодом 201 и заголовком Location, повтор того же
+// заказа — с кодом 200, а заказ с тем же order_uid и другим содержимым — 409;
+// заказ, не прошедший проверку, — 422 с ошибками по полям (см. writeRejected).
+// Повтор запроса с тем же Idempotency-Key возвращает результат первого запроса.
+func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
+	log := h.logger(r)
+
+	key := r.Header.Get("Idempotency-Key")
+	if len(key) > maxIdempotencyKeyLen {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Idempotency-Key не может быть длиннее "+strconv.Itoa(maxIdempotencyKeyLen)+" символов")
+		return
+	}
+
+	version := schema.V1
+	if v := r.Header.Get("X-Schema-Version"); v != "" {
+		var err error
+		if version, err = strconv.Atoi(v); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "X-Schema-Version должен быть числом")
+			return
+		}
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxOrderBodySize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeProblem(w, r, http.StatusRequestEntityTooLarge, CodePayloadTooLarge, "Тело запроса слишком большое")
+			return
+		}
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Не удалось прочитать тело запроса")
+		return
+	}
+
+	order, err := h.ingest.Decode(r.Header.Get("Content-Type"), version, body)
+	if err != nil {
+		if errors.Is(err, codec.ErrUnsupportedFormat) {
+			writeProblem(w, r, http.StatusUnsupportedMediaType, CodeUnsupportedMedia, err.Error())
+			return
+		}
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Некорректное тело запроса: "+err.Error())
+		return
+	}
+	log = log.With("order_uid", order.OrderUID)
+
+	if err := h.ingest.Validate(order); err != nil {
+		log.Warn("заказ отклонён", "error", err)
+		writeRejected(w, r, err)
+		return
+	}
+
+	result, err := h.ingest.Submit(r.Context(), order, key)
+	if err != nil {
+		writeStorageError(w, r, log.With("idempotency_key", key), err, "Не удалось сохранить заказ")
+		return
+	}
+	log.Info("заказ принят через API", "result", result.String())
+
+	status := http.StatusOK
+	if result == database.SaveCreated {
+		status = http.StatusCreated
+	}
+	w.Header().Set("Location", "/api/order/"+url.PathEscape(order.OrderUID))
+	h.writeOrder(w, r, status, order)
+}
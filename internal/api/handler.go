@@ -2,59 +2,371 @@ package api
 
 import (
 	"L0_project/internal/cache"
+	"L0_project/internal/codec"
 	"L0_project/internal/database"
+	"L0_project/internal/ingest"
+	"L0_project/internal/model"
 	"encoding/json"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 )
 
 type Handler struct {
-	db    database.OrderStorage
-	cache cache.OrderCache
+	db     database.OrderStorage
+	cache  cache.OrderCache
+	ingest *ingest.Service
+	log    *slog.Logger
 }
 
-func NewHandler(db database.OrderStorage, cache cache.OrderCache) *Handler {
-	return &Handler{db: db, cache: cache}
+func NewHandler(db database.OrderStorage, cache cache.OrderCache, ingest *ingest.Service, log *slog.Logger) *Handler {
+	return &Handler{db: db, cache: cache, ingest: ingest, log: log.With("component", "http")}
+}
+
+// logger возвращает логгер с request ID текущего запроса.
+func (h *Handler) logger(r *http.Request) *slog.Logger {
+	return h.log.With("request_id", middleware.GetReqID(r.Context()))
 }
 
 func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
 	orderUID := chi.URLParam(r, "orderUID")
 	if orderUID == "" {
-		http.Error(w, "Идентификатор заказа обязателен", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Идентификатор заказа обязателен")
 		return
 	}
+	log := h.logger(r)
 
 	if order, found := h.cache.Get(orderUID); found {
-		log.Printf("Cache HIT для заказа: %s", orderUID)
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(order)
+		log.Debug("cache hit", "order_uid", orderUID)
+		h.writeOrder(w, r, http.StatusOK, order)
 		return
 	}
 
-	log.Printf("Cache MISS для заказа: %s. Загрузка из базы данных.", orderUID)
+	log.Debug("cache miss, загрузка из базы данных", "order_uid", orderUID)
 	order, err := h.db.GetOrder(r.Context(), orderUID)
 	if err != nil {
-		log.Printf("Ошибка получения заказа из базы данных: %v", err)
-		http.Error(w, "Заказ не найден", http.StatusNotFound)
+		writeStorageError(w, r, log.With("order_uid", orderUID), err, "Не удалось получить заказ")
 		return
 	}
 
 	h.cache.Add(orderUID, order)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(order)
+	h.writeOrder(w, r, http.StatusOK, order)
 }
 
 func (h *Handler) GetRecentOrders(w http.ResponseWriter, r *http.Request) {
 	orders, err := h.db.GetRecentOrders(r.Context(), 10)
 	if err != nil {
-		log.Printf("Ошибка получения последних заказов из базы данных: %v", err)
-		http.Error(w, "Не удалось получить последние заказы", http.StatusInternalServerError)
+		writeStorageError(w, r, h.logger(r), err, "Не удалось получить последние заказы")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(orders)
 }
+
+// maxBatchOrders ограничивает число заказов в одном запросе GetOrdersBatch.
+const maxBatchOrders = 100
+
+// GetOrdersBatch возвращает несколько заказов по списку идентификаторов (?uids=a,b,c).
+// Заказы из кэша отдаются сразу, остальные загружаются из базы одним вызовом GetOrders.
+func (h *Handler) GetOrdersBatch(w http.ResponseWriter, r *http.Request) {
+	var uids []string
+	for _, uid := range strings.Split(r.URL.Query().Get("uids"), ",") {
+		if uid = strings.TrimSpace(uid); uid != "" {
+			uids = append(uids, uid)
+		}
+	}
+	if len(uids) == 0 {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Список идентификаторов заказов обязателен")
+		return
+	}
+	if len(uids) > maxBatchOrders {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("Можно запросить не более %d заказов", maxBatchOrders))
+		return
+	}
+
+	found := make(map[string]*model.Order, len(uids))
+	var missing []string
+	for _, uid := range uids {
+		if order, ok := h.cache.Get(uid); ok {
+			found[uid] = order
+		} else {
+			missing = append(missing, uid)
+		}
+	}
+
+	if len(missing) > 0 {
+		orders, err := h.db.GetOrders(r.Context(), missing)
+		if err != nil {
+			writeStorageError(w, r, h.logger(r).With("order_uids", missing), err, "Не удалось получить заказы")
+			return
+		}
+		for i := range orders {
+			order := &orders[i]
+			h.cache.Add(order.OrderUID, order)
+			found[order.OrderUID] = order
+		}
+	}
+
+	result := make([]*model.Order, 0, len(found))
+	for _, uid := range uids {
+		if order, ok := found[uid]; ok {
+			result = append(result, order)
+			delete(found, uid)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// orderListResponse — ответ ListOrders.
+type orderListResponse struct {
+	Orders     []model.Order `json:"orders"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// ListOrders возвращает страницу заказов с фильтрами и курсорной пагинацией.
+func (h *Handler) ListOrders(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := database.OrderFilter{
+		CustomerID:      q.Get("customer_id"),
+		TrackNumber:     q.Get("track_number"),
+		DeliveryService: q.Get("delivery_service"),
+		Locale:          q.Get("locale"),
+		Currency:        q.Get("currency"),
+		Provider:        q.Get("provider"),
+		Bank:            q.Get("bank"),
+	}
+
+	limit, cursor, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+	filter.Limit = limit
+
+	var err error
+	if v := q.Get("from"); v != "" {
+		if filter.CreatedFrom, err = time.Parse(time.RFC3339, v); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Параметр from должен быть в формате RFC 3339")
+			return
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if filter.CreatedTo, err = time.Parse(time.RFC3339, v); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Параметр to должен быть в формате RFC 3339")
+			return
+		}
+	}
+
+	page, err := h.db.ListOrders(r.Context(), filter, cursor)
+	if err != nil {
+		writeStorageError(w, r, h.logger(r), err, "Не удалось получить список заказов")
+		return
+	}
+
+	writeOrderPage(w, page)
+}
+
+// GetOrderByTrackNumber возвращает заказ по трек-номеру. Сначала заказ ищется
+// в кэше по вторичному ключу.
+func (h *Handler) GetOrderByTrackNumber(w http.ResponseWriter, r *http.Request) {
+	track := chi.URLParam(r, "track")
+	if track == "" {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Трек-номер обязателен")
+		return
+	}
+	log := h.logger(r)
+
+	if order, found := h.cache.GetByTrackNumber(track); found {
+		log.Debug("cache hit", "track_number", track)
+		h.writeOrder(w, r, http.StatusOK, order)
+		return
+	}
+
+	order, err := h.db.GetOrderByTrackNumber(r.Context(), track)
+	if err != nil {
+		writeStorageError(w, r, log.With("track_number", track), err, "Не удалось получить заказ")
+		return
+	}
+
+	h.cache.Add(order.OrderUID, order)
+
+	h.writeOrder(w, r, http.StatusOK, order)
+}
+
+// GetOrderByTransaction возвращает заказ по идентификатору платёжной транзакции.
+func (h *Handler) GetOrderByTransaction(w http.ResponseWriter, r *http.Request) {
+	transaction := chi.URLParam(r, "transaction")
+	if transaction == "" {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Идентификатор транзакции обязателен")
+		return
+	}
+
+	order, err := h.db.GetOrderByTransaction(r.Context(), transaction)
+	if err != nil {
+		writeStorageError(w, r, h.logger(r).With("transaction", transaction), err, "Не удалось получить заказ")
+		return
+	}
+
+	h.cache.Add(order.OrderUID, order)
+
+	h.writeOrder(w, r, http.StatusOK, order)
+}
+
+// GetCustomerOrders возвращает заказы покупателя с курсорной пагинацией.
+func (h *Handler) GetCustomerOrders(w http.ResponseWriter, r *http.Request) {
+	customerID := chi.URLParam(r, "customerID")
+	if customerID == "" {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Идентификатор покупателя обязателен")
+		return
+	}
+
+	limit, cursor, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := h.db.GetCustomerOrders(r.Context(), customerID, cursor, limit)
+	if err != nil {
+		writeStorageError(w, r, h.logger(r).With("customer_id", customerID), err, "Не удалось получить заказы покупателя")
+		return
+	}
+
+	writeOrderPage(w, page)
+}
+
+// orderStatusResponse — текущий статус заказа и история его изменений.
+type orderStatusResponse struct {
+	OrderUID string               `json:"order_uid"`
+	Status   model.OrderStatus    `json:"status"`
+	History  []model.StatusChange `json:"history"`
+}
+
+// GetOrderStatus возвращает текущий статус заказа и историю переходов.
+func (h *Handler) GetOrderStatus(w http.ResponseWriter, r *http.Request) {
+	orderUID := chi.URLParam(r, "orderUID")
+	h.writeOrderStatus(w, r, orderUID)
+}
+
+// UpdateOrderStatus переводит заказ в новый статус: {"status": "paid"}.
+// Недопустимый переход возвращает 409 с кодом invalid_transition.
+func (h *Handler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
+	orderUID := chi.URLParam(r, "orderUID")
+	log := h.logger(r).With("order_uid", orderUID)
+
+	var req struct {
+		Status model.OrderStatus `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Некорректное тело запроса")
+		return
+	}
+	if !req.Status.Valid() {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("Неизвестный статус заказа %q", req.Status))
+		return
+	}
+
+	changed, err := h.db.UpdateStatus(r.Context(), orderUID, req.Status, model.StatusSourceAPI)
+	if err != nil {
+		writeStorageError(w, r, log.With("status", req.Status), err, "Не удалось изменить статус заказа")
+		return
+	}
+
+	if changed {
+		log.Info("статус заказа изменён", "status", req.Status)
+		h.cache.Remove(orderUID)
+	}
+	h.writeOrderStatus(w, r, orderUID)
+}
+
+func (h *Handler) writeOrderStatus(w http.ResponseWriter, r *http.Request, orderUID string) {
+	history, err := h.db.GetStatusHistory(r.Context(), orderUID)
+	if err != nil {
+		writeStorageError(w, r, h.logger(r).With("order_uid", orderUID), err, "Не удалось получить статус заказа")
+		return
+	}
+
+	resp := orderStatusResponse{
+		OrderUID: orderUID,
+		Status:   history[len(history)-1].To,
+		History:  history,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// writeOrder отдаёт заказ с кодом status в формате, выбранном по заголовку Accept:
+// JSON (по умолчанию), Protobuf или Avro. Если ни один формат не подходит — 406.
+// Версия заказа отдаётся в ETag для If-Match при изменении заказа.
+func (h *Handler) writeOrder(w http.ResponseWriter, r *http.Request, status int, order *model.Order) {
+	w.Header().Add("Vary", "Accept")
+	if order.Version > 0 {
+		w.Header().Set("ETag", etag(order.Version))
+	}
+	cd, ok := codec.Negotiate(r.Header.Get("Accept"))
+	if !ok {
+		writeProblem(w, r, http.StatusNotAcceptable, CodeNotAcceptable, "Поддерживаются форматы "+strings.Join(codec.ContentTypes(), ", "))
+		return
+	}
+	if cd == codec.JSON {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(order)
+		return
+	}
+
+	body, err := cd.Marshal(order)
+	if err != nil {
+		h.logger(r).Error("не удалось сериализовать заказ", "order_uid", order.OrderUID, "format", cd.Name(), "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, CodeInternal, "Не удалось сериализовать заказ")
+		return
+	}
+	w.Header().Set("Content-Type", cd.ContentType())
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// parsePage разбирает параметры пагинации limit и cursor. При ошибке ответ уже записан.
+func parsePage(w http.ResponseWriter, r *http.Request) (int, *database.Cursor, bool) {
+	q := r.URL.Query()
+
+	var limit int
+	if v := q.Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Параметр limit должен быть положительным числом")
+			return 0, nil, false
+		}
+	}
+
+	var cursor *database.Cursor
+	if v := q.Get("cursor"); v != "" {
+		var err error
+		if cursor, err = database.DecodeCursor(v); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Некорректный курсор")
+			return 0, nil, false
+		}
+	}
+	return limit, cursor, true
+}
+
+func writeOrderPage(w http.ResponseWriter, page database.OrderPage) {
+	resp := orderListResponse{Orders: page.Orders}
+	if resp.Orders == nil {
+		resp.Orders = []model.Order{}
+	}
+	if page.Next != nil {
+		resp.NextCursor = page.Next.Encode()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"L0_project/internal/database"
+	"L0_project/internal/ingest"
+	"L0_project/internal/model"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// Коды ошибок в поле code ответа problem+json. Коды стабильны: по ним фронтенд
+// выбирает локализованный текст, а detail — подробность для разработчика.
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeOrderNotFound        = "order_not_found"
+	CodeItemNotFound         = "item_not_found"
+	CodeNotAcceptable        = "not_acceptable"
+	CodeUnsupportedMedia     = "unsupported_media_type"
+	CodePayloadTooLarge      = "payload_too_large"
+	CodeValidationFailed     = "validation_failed"
+	CodeIdempotencyKeyReused = "idempotency_key_reused"
+	CodeOrderExists          = "order_exists"
+	CodeDataRejected         = "data_rejected"
+	CodeInvalidTransition    = "invalid_transition"
+	CodeNotAmendable         = "not_amendable"
+	CodeConflict             = "conflict"
+	CodeVersionMismatch      = "version_mismatch"
+	CodePreconditionRequired = "precondition_required"
+	CodeUnavailable          = "service_unavailable"
+	CodeInternal             = "internal_error"
+)
+
+// problemContentType — тип ответа с ошибкой по RFC 7807.
+const problemContentType = "application/problem+json"
+
+// problem — тело ответа с ошибкой по RFC 7807. Type всегда about:blank, поэтому
+// title — стандартный текст статуса; ошибку различает расширение code.
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+	// Stage и Fields заполняются для validation_failed.
+	Stage  string              `json:"stage,omitempty"`
+	Fields []ingest.FieldError `json:"fields,omitempty"`
+}
+
+// writeProblem отвечает ошибкой status с кодом code и текстом detail.
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	writeProblemBody(w, r, problem{Status: status, Code: code, Detail: detail})
+}
+
+func writeProblemBody(w http.ResponseWriter, r *http.Request, p problem) {
+	p.Type = "about:blank"
+	p.Title = http.StatusText(p.Status)
+	p.Instance = r.URL.Path
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// writeRejected отвечает 422 с ошибками по полям заказа, не прошедшего проверку.
+func writeRejected(w http.ResponseWriter, r *http.Request, err error) {
+	p := problem{Status: http.StatusUnprocessableEntity, Code: CodeValidationFailed, Detail: err.Error(), Stage: ingest.StageValidate}
+	var rejected *ingest.RejectedError
+	if errors.As(err, &rejected) {
+		p.Stage = rejected.Stage
+		p.Fields = rejected.Fields
+	}
+	writeProblemBody(w, r, p)
+}
+
+// writeStorageError отвечает на ошибку хранилища по её виду (database.ErrNotFound,
+// ErrConflict, ErrUnavailable). Неожиданные ошибки логируются и отдаются как 500
+// с текстом failure, без подробностей из базы.
+func writeStorageError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error, failure string) {
+	var rejected *ingest.RejectedError
+	switch {
+	case errors.As(err, &rejected):
+		log.Warn("заказ не прошёл проверку", "error", err)
+		writeRejected(w, r, err)
+	case errors.Is(err, database.ErrNotFound):
+		writeProblem(w, r, http.StatusNotFound, CodeOrderNotFound, "Заказ не найден")
+	case errors.Is(err, database.ErrVersionMismatch):
+		log.Warn("заказ изменён другим запросом", "error", err)
+		writeProblem(w, r, http.StatusPreconditionFailed, CodeVersionMismatch, err.Error())
+	case errors.Is(err, database.ErrIdempotencyKeyReused):
+		log.Warn("повтор ключа идемпотентности с другим заказом", "error", err)
+		writeProblem(w, r, http.StatusUnprocessableEntity, CodeIdempotencyKeyReused, err.Error())
+	case errors.Is(err, database.ErrOrderExists):
+		log.Warn("заказ с таким order_uid уже существует", "error", err)
+		writeProblem(w, r, http.StatusConflict, CodeOrderExists, err.Error())
+	case errors.Is(err, model.ErrInvalidTransition):
+		log.Warn("недопустимый переход статуса", "error", err)
+		writeProblem(w, r, http.StatusConflict, CodeInvalidTransition, err.Error())
+	case errors.Is(err, model.ErrNotAmendable):
+		log.Warn("изменение заказа отклонено", "error", err)
+		writeProblem(w, r, http.StatusConflict, CodeNotAmendable, err.Error())
+	case errors.Is(err, database.ErrConflict):
+		log.Warn("конфликт с текущим состоянием заказа", "error", err)
+		writeProblem(w, r, http.StatusConflict, CodeConflict, err.Error())
+	case database.IsDataError(err):
+		log.Warn("данные отклонены базой данных", "error", err)
+		writeProblem(w, r, http.StatusUnprocessableEntity, CodeDataRejected, "Данные отклонены базой данных")
+	case errors.Is(err, database.ErrUnavailable):
+		log.Error("база данных недоступна", "error", err)
+		w.Header().Set("Retry-After", "5")
+		writeProblem(w, r, http.StatusServiceUnavailable, CodeUnavailable, "Сервис временно недоступен")
+	default:
+		log.Error(failure, "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, CodeInternal, failure)
+	}
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"L0_project/internal/database"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Ограничения длины запроса GET /api/search в символах.
+const (
+	minSearchQueryLen = 2
+	maxSearchQueryLen = 200
+)
+
+// searchResponse — ответ SearchOrders.
+type searchResponse struct {
+	Query   string               `json:"query"`
+	Results []database.SearchHit `json:"results"`
+}
+
+// SearchOrders ищет заказы по фрагментам имени, адреса, email или телефона
+// получателя, названия или бренда товара, трек-номера и идентификаторов:
+// GET /api/search?q=...&limit=N. Результаты упорядочены по релевантности,
+// совпавшие слова в highlights обрамлены <mark>...</mark>.
+func (h *Handler) SearchOrders(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if n := utf8.RuneCountInString(query); n < minSearchQueryLen || n > maxSearchQueryLen {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest,
+			"Параметр q должен содержать от "+strconv.Itoa(minSearchQueryLen)+" до "+strconv.Itoa(maxSearchQueryLen)+" символов")
+		return
+	}
+
+	limit := database.DefaultPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Параметр limit должен быть положительным числом")
+			return
+		}
+		limit = min(limit, database.MaxPageSize)
+	}
+
+	hits, err := h.db.SearchOrders(r.Context(), query, limit)
+	if err != nil {
+		writeStorageError(w, r, h.logger(r).With("query", query), err, "Не удалось выполнить поиск заказов")
+		return
+	}
+
+	resp := searchResponse{Query: query, Results: hits}
+	if resp.Results == nil {
+		resp.Results = []database.SearchHit{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -1,25 +1,48 @@
 package api
 
 import (
+	"L0_project/internal/health"
+	"L0_project/internal/metrics"
 	"fmt"
+	"log/slog"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
 
-func NewRouter(h *Handler) *chi.Mux {
+func NewRouter(h *Handler, hc *health.Checker, log *slog.Logger) *chi.Mux {
 	r := chi.NewRouter()
 
-	r.Use(middleware.Logger)
+	r.Use(middleware.RequestID)
+	r.Use(requestLogger(log))
 	r.Use(middleware.Recoverer)
+	r.Use(instrument)
+
+	r.Handle("/metrics", metrics.Handler())
+	r.Get("/healthz", hc.Liveness)
+	r.Get("/readyz", hc.Readiness)
 
 	fs := http.FileServer(http.Dir("./web/"))
 	r.Handle("/*", fs)
 
 	r.Route("/api", func(r chi.Router) {
 		r.Get("/order/{orderUID}", h.GetOrder)
+		r.Get("/order/{orderUID}/status", h.GetOrderStatus)
+		r.Post("/order/{orderUID}/status", h.UpdateOrderStatus)
+		r.Patch("/order/{orderUID}/delivery", h.AmendDelivery)
+		r.Post("/order/{orderUID}/cancel", h.CancelOrder)
+		r.Patch("/order/{orderUID}/items/{rid}", h.AmendItem)
+		r.Delete("/order/{orderUID}/items/{rid}", h.RemoveItem)
+		r.Get("/order/{orderUID}/audit", h.GetOrderAudit)
+		r.Get("/orders", h.ListOrders)
+		r.Post("/orders", h.CreateOrder)
 		r.Get("/orders/recent", h.GetRecentOrders)
+		r.Get("/orders/batch", h.GetOrdersBatch)
+		r.Get("/orders/by-track/{track}", h.GetOrderByTrackNumber)
+		r.Get("/orders/by-transaction/{transaction}", h.GetOrderByTransaction)
+		r.Get("/customers/{customerID}/orders", h.GetCustomerOrders)
+		r.Get("/search", h.SearchOrders)
 	})
 
 	return r
@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"L0_project/internal/model"
+	"fmt"
+	"time"
+	"unsafe"
+)
+
+// EvictReason — причина удаления заказа из кэша.
+type EvictReason int
+
+const (
+	// EvictCapacity — превышено максимальное число заказов.
+	EvictCapacity EvictReason = iota + 1
+	// EvictBytes — превышен бюджет памяти.
+	EvictBytes
+	// EvictExpired — истёк TTL записи.
+	EvictExpired
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictBytes:
+		return "bytes"
+	case EvictExpired:
+		return "expired"
+	default:
+		return "unknown"
+	}
+}
+
+// EvictFunc вызывается после удаления заказа из кэша, вне блокировок кэша.
+type EvictFunc func(key string, order *model.Order, reason EvictReason)
+
+// Реализации OrderCache, доступные через New.
+const (
+	ImplLRU     = "lru"
+	ImplSharded = "sharded"
+)
+
+// DefaultShards — число сегментов shardedCache по умолчанию.
+const DefaultShards = 16
+
+// Config задаёт ограничения кэша. Нулевые значения отключают соответствующее ограничение.
+type Config struct {
+	// Capacity — максимальное число заказов.
+	Capacity int
+	// TTL — время жизни записи с момента добавления.
+	TTL time.Duration
+	// MaxBytes — бюджет памяти по оценке EstimateSize.
+	MaxBytes int64
+	// OnEvict вызывается при вытеснении заказа.
+	OnEvict EvictFunc
+	// Shards — число сегментов для ImplSharded, по умолчанию DefaultShards.
+	Shards int
+}
+
+// New создает кэш выбранной реализации.
+func New(impl string, cfg Config) (OrderCache, error) {
+	switch impl {
+	case ImplLRU, "":
+		return NewLRUCache(cfg), nil
+	case ImplSharded:
+		return NewShardedCache(cfg), nil
+	default:
+		return nil, fmt.Errorf("неизвестная реализация кэша: %q", impl)
+	}
+}
+
+// Размеры структур без учёта содержимого строк и слайсов.
+var (
+	orderOverhead = int64(unsafe.Sizeof(model.Order{}))
+	itemOverhead  = int64(unsafe.Sizeof(model.Item{}))
+)
+
+// EstimateSize оценивает объём памяти, занимаемый заказом: размеры структур
+// плюс длины всех строк. Оценка приблизительная, но пропорциональна числу товаров.
+func EstimateSize(order *model.Order) int64 {
+	if order == nil {
+		return 0
+	}
+	size := orderOverhead + int64(
+		len(order.OrderUID)+len(order.TrackNumber)+len(order.Entry)+len(order.Locale)+
+			len(order.InternalSignature)+len(order.CustomerID)+len(order.DeliveryService)+
+			len(order.Shardkey)+len(order.OofShard))
+
+	d := order.Delivery
+	size += int64(len(d.Name) + len(d.Phone) + len(d.Zip) + len(d.City) + len(d.Address) + len(d.Region) + len(d.Email))
+
+	p := order.Payment
+	size += int64(len(p.Transaction) + len(p.RequestID) + len(p.Currency) + len(p.Provider) + len(p.Bank))
+
+	for i := range order.Items {
+		it := &order.Items[i]
+		size += itemOverhead + int64(len(it.TrackNumber)+len(it.Rid)+len(it.Name)+len(it.Size)+len(it.Brand)+len(it.OrderUID))
+	}
+	return size
+}
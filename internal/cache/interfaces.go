@@ -6,4 +6,29 @@ import "L0_project/internal/model"
 type OrderCache interface {
 	Add(key string, order *model.Order)
 	Get(key string) (*model.Order, bool)
+	// GetByTrackNumber ищет заказ по вторичному ключу — трек-номеру.
+	GetByTrackNumber(trackNumber string) (*model.Order, bool)
+	// Remove удаляет заказ из кэша, например после его изменения другой репликой.
+	Remove(key string)
+	// Purge очищает кэш целиком, когда неизвестно, какие заказы устарели.
+	Purge()
+	// Stats возвращает счётчики использования кэша.
+	Stats() Stats
+}
+
+// Stats содержит счётчики использования кэша с момента создания.
+type Stats struct {
+	Hits   uint64
+	Misses uint64
+	// Evictions — заказы, вытесненные по числу записей или бюджету памяти.
+	Evictions uint64
+	// Expirations — заказы, удалённые по истечении TTL.
+	Expirations uint64
+	// Size — текущее количество заказов в кэше.
+	Size int
+	// Bytes — оценка памяти, занятой заказами (см. EstimateSize).
+	Bytes int64
+	// Capacity и MaxBytes — настроенные ограничения, 0 — без ограничения.
+	Capacity int
+	MaxBytes int64
 }
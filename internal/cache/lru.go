@@ -4,66 +4,220 @@ import (
 	"L0_project/internal/model"
 	"container/list"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type lruCache struct {
-	mu       sync.RWMutex
+	mu       sync.Mutex
 	capacity int
+	ttl      time.Duration
+	maxBytes int64
+	onEvict  EvictFunc
 	items    map[string]*list.Element
 	queue    *list.List
+	// tracks — вторичный индекс: трек-номер -> order_uid.
+	tracks map[string]string
+	bytes  int64
+
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	evictions   atomic.Uint64
+	expirations atomic.Uint64
 }
 
 type cacheItem struct {
-	key   string
-	value *model.Order
+	key       string
+	value     *model.Order
+	size      int64
+	expiresAt time.Time
+}
+
+// evicted — запись, удалённая под блокировкой; OnEvict для неё вызывается после разблокировки.
+type evicted struct {
+	item   *cacheItem
+	reason EvictReason
 }
 
 // NewLRUCache создает новый экземпляр LRU-кэша.
-func NewLRUCache(capacity int) OrderCache {
+func NewLRUCache(cfg Config) OrderCache {
 	return &lruCache{
-		capacity: capacity,
+		capacity: cfg.Capacity,
+		ttl:      cfg.TTL,
+		maxBytes: cfg.MaxBytes,
+		onEvict:  cfg.OnEvict,
 		items:    make(map[string]*list.Element),
 		queue:    list.New(),
+		tracks:   make(map[string]string),
 	}
 }
 
-// Add добавляет заказ в кэш.
+// Add добавляет заказ в кэш. Заказ, который сам по себе больше бюджета памяти, не кэшируется.
 func (c *lruCache) Add(key string, order *model.Order) {
+	size := EstimateSize(order)
+	if c.maxBytes > 0 && size > c.maxBytes {
+		return
+	}
+
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	var removed []evicted
 
 	if element, exists := c.items[key]; exists {
 		c.queue.MoveToFront(element)
-		element.Value.(*cacheItem).value = order
-		return
+		item := element.Value.(*cacheItem)
+		c.unindex(key, item.value)
+		c.bytes += size - item.size
+		item.value = order
+		item.size = size
+		item.expiresAt = c.expiry()
+		c.index(key, order)
+	} else {
+		if c.capacity > 0 && c.queue.Len() >= c.capacity {
+			removed = append(removed, c.removeOldest(EvictCapacity))
+		}
+		item := &cacheItem{key: key, value: order, size: size, expiresAt: c.expiry()}
+		c.items[key] = c.queue.PushFront(item)
+		c.bytes += size
+		c.index(key, order)
 	}
 
-	if c.queue.Len() == c.capacity {
-		c.removeOldest()
+	for c.maxBytes > 0 && c.bytes > c.maxBytes && c.queue.Len() > 1 {
+		removed = append(removed, c.removeOldest(EvictBytes))
 	}
+	c.mu.Unlock()
 
-	item := &cacheItem{key: key, value: order}
-	element := c.queue.PushFront(item)
-	c.items[key] = element
+	c.notify(removed)
 }
 
-// Get извлекает заказ из кэша.
+// Get извлекает заказ из кэша. Просроченная запись удаляется и считается промахом.
+// Блокировка эксклюзивная: перемещение в начало очереди изменяет список.
 func (c *lruCache) Get(key string) (*model.Order, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	order, ok, expired := c.get(key)
+	c.mu.Unlock()
+
+	if expired != nil {
+		c.notify([]evicted{*expired})
+	}
+	return order, ok
+}
 
+// GetByTrackNumber извлекает заказ из кэша по трек-номеру.
+func (c *lruCache) GetByTrackNumber(trackNumber string) (*model.Order, bool) {
+	c.mu.Lock()
+	key, exists := c.tracks[trackNumber]
+	if !exists {
+		c.mu.Unlock()
+		c.misses.Add(1)
+		return nil, false
+	}
+	order, ok, expired := c.get(key)
+	c.mu.Unlock()
+
+	if expired != nil {
+		c.notify([]evicted{*expired})
+	}
+	return order, ok
+}
+
+// Remove удаляет заказ из кэша. OnEvict не вызывается: удаление явное.
+func (c *lruCache) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if element, exists := c.items[key]; exists {
-		c.queue.MoveToFront(element)
-		return element.Value.(*cacheItem).value, true
+		c.remove(element)
+	}
+}
+
+// Purge удаляет все заказы, счётчики сохраняются.
+func (c *lruCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = make(map[string]*list.Element)
+	c.queue.Init()
+	c.tracks = make(map[string]string)
+	c.bytes = 0
+}
+
+// Stats возвращает счётчики использования кэша.
+func (c *lruCache) Stats() Stats {
+	c.mu.Lock()
+	size, bytes := c.queue.Len(), c.bytes
+	c.mu.Unlock()
+
+	return Stats{
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+		Evictions:   c.evictions.Load(),
+		Expirations: c.expirations.Load(),
+		Size:        size,
+		Bytes:       bytes,
+		Capacity:    c.capacity,
+		MaxBytes:    c.maxBytes,
 	}
+}
 
-	return nil, false
+// get выполняется под блокировкой.
+func (c *lruCache) get(key string) (*model.Order, bool, *evicted) {
+	element, exists := c.items[key]
+	if !exists {
+		c.misses.Add(1)
+		return nil, false, nil
+	}
+
+	item := element.Value.(*cacheItem)
+	if !item.expiresAt.IsZero() && time.Now().After(item.expiresAt) {
+		c.remove(element)
+		c.expirations.Add(1)
+		c.misses.Add(1)
+		return nil, false, &evicted{item: item, reason: EvictExpired}
+	}
+
+	c.queue.MoveToFront(element)
+	c.hits.Add(1)
+	return item.value, true, nil
+}
+
+func (c *lruCache) expiry() time.Time {
+	if c.ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(c.ttl)
 }
 
-func (c *lruCache) removeOldest() {
+func (c *lruCache) removeOldest(reason EvictReason) evicted {
 	element := c.queue.Back()
-	if element != nil {
-		item := c.queue.Remove(element).(*cacheItem)
-		delete(c.items, item.key)
+	item := c.remove(element)
+	c.evictions.Add(1)
+	return evicted{item: item, reason: reason}
+}
+
+func (c *lruCache) remove(element *list.Element) *cacheItem {
+	item := c.queue.Remove(element).(*cacheItem)
+	delete(c.items, item.key)
+	c.unindex(item.key, item.value)
+	c.bytes -= item.size
+	return item
+}
+
+func (c *lruCache) notify(removed []evicted) {
+	if c.onEvict == nil {
+		return
+	}
+	for _, e := range removed {
+		c.onEvict(e.item.key, e.item.value, e.reason)
+	}
+}
+
+func (c *lruCache) index(key string, order *model.Order) {
+	if order != nil && order.TrackNumber != "" {
+		c.tracks[order.TrackNumber] = key
+	}
+}
+
+// unindex удаляет вторичный ключ, только если он всё ещё указывает на key.
+func (c *lruCache) unindex(key string, order *model.Order) {
+	if order != nil && c.tracks[order.TrackNumber] == key {
+		delete(c.tracks, order.TrackNumber)
 	}
 }
@@ -0,0 +1,197 @@
+package cache
+
+import (
+	"L0_project/internal/model"
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// RedisConfig задаёт параметры общего кэша в Redis.
+type RedisConfig struct {
+	// Prefix добавляется ко всем ключам, например "orders:".
+	Prefix string
+	// TTL — время жизни записей в Redis, 0 — без истечения.
+	TTL time.Duration
+	// Timeout ограничивает одно обращение к Redis. По умолчанию 100 мс.
+	Timeout time.Duration
+}
+
+// redisCache — общий для всех реплик кэш в Redis с локальным кэшем (L1) перед ним.
+// Ошибки Redis не пробрасываются вызывающему: Get возвращает промах, и заказ
+// загружается из базы, как при отсутствии кэша.
+type redisCache struct {
+	client  redis.UniversalClient
+	local   OrderCache
+	prefix  string
+	ttl     time.Duration
+	timeout time.Duration
+	log     *slog.Logger
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// NewRedisCache создает кэш в Redis; local используется как L1. Чтобы cfg.Timeout
+// ограничивал обращения, клиент должен быть создан с ContextTimeoutEnabled.
+func NewRedisCache(client redis.UniversalClient, local OrderCache, cfg RedisConfig, log *slog.Logger) OrderCache {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 100 * time.Millisecond
+	}
+	return &redisCache{
+		client:  client,
+		local:   local,
+		prefix:  cfg.Prefix,
+		ttl:     cfg.TTL,
+		timeout: cfg.Timeout,
+		log:     log.With("component", "redis_cache"),
+	}
+}
+
+func (c *redisCache) orderKey(uid string) string {
+	return c.prefix + "order:" + uid
+}
+
+func (c *redisCache) trackKey(track string) string {
+	return c.prefix + "track:" + track
+}
+
+// Add записывает заказ в L1 и в Redis вместе со вторичным ключом трек-номера.
+func (c *redisCache) Add(key string, order *model.Order) {
+	c.local.Add(key, order)
+
+	data, err := encodeOrder(order)
+	if err != nil {
+		c.log.Error("не удалось сериализовать заказ", "order_uid", key, "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	_, err = c.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, c.orderKey(key), data, c.ttl)
+		if order.TrackNumber != "" {
+			p.Set(ctx, c.trackKey(order.TrackNumber), key, c.ttl)
+		}
+		return nil
+	})
+	if err != nil {
+		c.log.Warn("не удалось записать заказ в Redis", "order_uid", key, "error", err)
+	}
+}
+
+// Get ищет заказ в L1, затем в Redis. Найденный в Redis заказ добавляется в L1.
+func (c *redisCache) Get(key string) (*model.Order, bool) {
+	if order, ok := c.local.Get(key); ok {
+		c.hits.Add(1)
+		return order, true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	data, err := c.client.Get(ctx, c.orderKey(key)).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			c.log.Warn("не удалось прочитать заказ из Redis", "order_uid", key, "error", err)
+		}
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	order, err := decodeOrder(data)
+	if err != nil {
+		c.log.Warn("не удалось разобрать заказ из Redis", "order_uid", key, "error", err)
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	c.local.Add(key, order)
+	c.hits.Add(1)
+	return order, true
+}
+
+// GetByTrackNumber ищет заказ по трек-номеру в L1, затем через вторичный ключ в Redis.
+func (c *redisCache) GetByTrackNumber(trackNumber string) (*model.Order, bool) {
+	if order, ok := c.local.GetByTrackNumber(trackNumber); ok {
+		c.hits.Add(1)
+		return order, true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	key, err := c.client.Get(ctx, c.trackKey(trackNumber)).Result()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			c.log.Warn("не удалось прочитать трек-номер из Redis", "track_number", trackNumber, "error", err)
+		}
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	order, ok := c.Get(key)
+	if !ok || order.TrackNumber != trackNumber {
+		return nil, false
+	}
+	return order, true
+}
+
+// Remove удаляет заказ из L1 и из Redis. Ключ трек-номера не удаляется:
+// GetByTrackNumber сверяет трек-номер найденного заказа.
+func (c *redisCache) Remove(key string) {
+	c.local.Remove(key)
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	if err := c.client.Del(ctx, c.orderKey(key)).Err(); err != nil {
+		c.log.Warn("не удалось удалить заказ из Redis", "order_uid", key, "error", err)
+	}
+}
+
+// Purge очищает только L1. Записи в Redis общие для всех реплик, их актуальность
+// поддерживается через Add и Remove, а устаревшие удаляются по TTL.
+func (c *redisCache) Purge() {
+	c.local.Purge()
+}
+
+// Stats возвращает попадания и промахи с учётом обоих уровней; размер
+// и вытеснения относятся к L1.
+func (c *redisCache) Stats() Stats {
+	st := c.local.Stats()
+	st.Hits = c.hits.Load()
+	st.Misses = c.misses.Load()
+	return st
+}
+
+// Заказы хранятся в MessagePack: компактнее JSON и быстрее разбираются.
+// Имена полей берутся из json-тегов модели, служебные поля (json:"-") не сохраняются.
+
+func encodeOrder(order *model.Order) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	if err := enc.Encode(order); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeOrder(data []byte) (*model.Order, error) {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	var order model.Order
+	if err := dec.Decode(&order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"L0_project/internal/model"
+	"io"
+	"log/slog"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func newRedisCache(t *testing.T, addr string, cfg RedisConfig) (OrderCache, OrderCache) {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: addr, ContextTimeoutEnabled: true})
+	t.Cleanup(func() { client.Close() })
+	local := NewShardedCache(Config{})
+	return NewRedisCache(client, local, cfg, discardLogger), local
+}
+
+func TestRedisCacheRoundTrip(t *testing.T) {
+	mr := miniredis.RunT(t)
+	c, _ := newRedisCache(t, mr.Addr(), RedisConfig{Prefix: "test:", TTL: time.Minute})
+
+	order := testOrder(1)
+	order.Status, order.Version = model.StatusCreated, 3
+	c.Add(order.OrderUID, order)
+
+	if !mr.Exists("test:order:" + order.OrderUID) {
+		t.Fatal("заказ не записан в Redis")
+	}
+	if got, _ := mr.Get("test:track:" + order.TrackNumber); got != order.OrderUID {
+		t.Errorf("ключ трек-номера = %q, ожидался %q", got, order.OrderUID)
+	}
+	if ttl := mr.TTL("test:order:" + order.OrderUID); ttl != time.Minute {
+		t.Errorf("TTL = %v, ожидался %v", ttl, time.Minute)
+	}
+
+	// Другая реплика с пустым L1 читает заказ из Redis.
+	other, _ := newRedisCache(t, mr.Addr(), RedisConfig{Prefix: "test:"})
+	got, ok := other.Get(order.OrderUID)
+	if !ok {
+		t.Fatal("заказ не найден в Redis")
+	}
+	// msgpack восстанавливает время в локальной зоне.
+	if !got.DateCreated.Equal(order.DateCreated) {
+		t.Errorf("date_created = %v, ожидалось %v", got.DateCreated, order.DateCreated)
+	}
+	got.DateCreated = order.DateCreated
+	if !reflect.DeepEqual(got, order) {
+		t.Errorf("после msgpack заказ отличается:\n got %+v\nwant %+v", got, order)
+	}
+}
+
+func TestRedisCacheFillsLocalOnHit(t *testing.T) {
+	mr := miniredis.RunT(t)
+	writer, _ := newRedisCache(t, mr.Addr(), RedisConfig{})
+	reader, local := newRedisCache(t, mr.Addr(), RedisConfig{})
+
+	order := testOrder(1)
+	writer.Add(order.OrderUID, order)
+
+	if _, ok := local.Get(order.OrderUID); ok {
+		t.Fatal("заказ уже в L1 до чтения")
+	}
+	if _, ok := reader.Get(order.OrderUID); !ok {
+		t.Fatal("заказ не найден в Redis")
+	}
+	if _, ok := local.Get(order.OrderUID); !ok {
+		t.Error("заказ из Redis не добавлен в L1")
+	}
+
+	// Следующее чтение обслуживается L1 даже без Redis.
+	mr.Close()
+	if _, ok := reader.Get(order.OrderUID); !ok {
+		t.Error("заказ из L1 не найден после остановки Redis")
+	}
+}
+
+func TestRedisCacheGetByTrackNumber(t *testing.T) {
+	mr := miniredis.RunT(t)
+	writer, _ := newRedisCache(t, mr.Addr(), RedisConfig{})
+	reader, _ := newRedisCache(t, mr.Addr(), RedisConfig{})
+
+	order := testOrder(1)
+	writer.Add(order.OrderUID, order)
+
+	got, ok := reader.GetByTrackNumber(order.TrackNumber)
+	if !ok || got.OrderUID != order.OrderUID {
+		t.Fatalf("GetByTrackNumber(%s) = %v, %v", order.TrackNumber, got, ok)
+	}
+	if _, ok := reader.GetByTrackNumber("UNKNOWN"); ok {
+		t.Error("найден заказ по неизвестному трек-номеру")
+	}
+
+	// Трек-номер сменился: старый вторичный ключ больше не находит заказ.
+	changed := testOrder(1)
+	changed.TrackNumber = "TRACK-NEW"
+	writer.Add(changed.OrderUID, changed)
+	reader.Remove(order.OrderUID)
+	writer.Add(changed.OrderUID, changed)
+	if _, ok := reader.GetByTrackNumber(order.TrackNumber); ok {
+		t.Error("заказ найден по прежнему трек-номеру")
+	}
+}
+
+func TestRedisCacheRemove(t *testing.T) {
+	mr := miniredis.RunT(t)
+	first, _ := newRedisCache(t, mr.Addr(), RedisConfig{})
+	second, _ := newRedisCache(t, mr.Addr(), RedisConfig{})
+
+	order := testOrder(1)
+	first.Add(order.OrderUID, order)
+	first.Remove(order.OrderUID)
+
+	if mr.Exists("order:" + order.OrderUID) {
+		t.Error("общий ключ заказа не удалён из Redis")
+	}
+	if _, ok := first.Get(order.OrderUID); ok {
+		t.Error("заказ найден после Remove")
+	}
+	if _, ok := second.Get(order.OrderUID); ok {
+		t.Error("другая реплика нашла удалённый заказ")
+	}
+}
+
+func TestRedisCacheFallsBackToLocalOnError(t *testing.T) {
+	mr := miniredis.RunT(t)
+	c, _ := newRedisCache(t, mr.Addr(), RedisConfig{})
+
+	cached := testOrder(1)
+	c.Add(cached.OrderUID, cached)
+
+	mr.SetError("LOADING Redis is loading the dataset in memory")
+	if _, ok := c.Get(cached.OrderUID); !ok {
+		t.Error("заказ из L1 не найден при ошибке Redis")
+	}
+
+	order := testOrder(2)
+	c.Add(order.OrderUID, order)
+	if _, ok := c.Get(order.OrderUID); !ok {
+		t.Error("заказ, добавленный при ошибке Redis, не попал в L1")
+	}
+	if _, ok := c.GetByTrackNumber(order.TrackNumber); !ok {
+		t.Error("заказ, добавленный при ошибке Redis, не найден по трек-номеру")
+	}
+	if _, ok := c.Get("missing"); ok {
+		t.Error("ошибка Redis вернула попадание")
+	}
+	c.Remove(order.OrderUID)
+}
+
+func TestRedisCacheFallsBackToLocalOnTimeout(t *testing.T) {
+	// Сервер принимает соединения, но не отвечает.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	const timeout = 50 * time.Millisecond
+	c, _ := newRedisCache(t, ln.Addr().String(), RedisConfig{Timeout: timeout})
+
+	order := testOrder(1)
+	start := time.Now()
+	c.Add(order.OrderUID, order)
+	got, ok := c.Get(order.OrderUID)
+	_, missOK := c.Get("missing")
+	elapsed := time.Since(start)
+
+	if !ok || got.OrderUID != order.OrderUID {
+		t.Error("заказ из L1 не найден, пока Redis не отвечает")
+	}
+	if missOK {
+		t.Error("таймаут Redis вернул попадание")
+	}
+	// Add и промах Get ждут Redis не дольше Timeout каждый.
+	if elapsed > 10*timeout {
+		t.Errorf("обращения к неотвечающему Redis заняли %v при Timeout %v", elapsed, timeout)
+	}
+}
@@ -0,0 +1,311 @@
+package cache
+
+import (
+	"L0_project/internal/model"
+	"hash/maphash"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// shardedCache — кэш, разбитый на сегменты по хэшу ключа. Каждый сегмент вытесняет
+// записи по алгоритму CLOCK: чтение лишь выставляет атомарный бит обращения под
+// разделяемой блокировкой, поэтому параллельные Get не сериализуются и не гоняются
+// за общий список, как MoveToFront в LRU.
+type shardedCache struct {
+	seed    maphash.Seed
+	shards  []*clockShard
+	tracks  []*trackShard
+	mask    uint64
+	ttl     time.Duration
+	onEvict EvictFunc
+
+	capacity int
+	maxBytes int64
+}
+
+type clockEntry struct {
+	key   string
+	value *model.Order
+	size  int64
+	// expiresAt — время истечения в UnixNano, 0 — без TTL.
+	expiresAt  int64
+	referenced atomic.Bool
+	// slot — позиция записи в кольце CLOCK.
+	slot int
+}
+
+type clockShard struct {
+	mu       sync.RWMutex
+	entries  map[string]*clockEntry
+	ring     []*clockEntry
+	hand     int
+	capacity int
+	maxBytes int64
+	bytes    int64
+
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	evictions   atomic.Uint64
+	expirations atomic.Uint64
+}
+
+// trackShard — сегмент вторичного индекса трек-номер -> order_uid.
+type trackShard struct {
+	mu sync.RWMutex
+	m  map[string]string
+}
+
+// NewShardedCache создает сегментированный кэш. Число сегментов округляется вверх
+// до степени двойки; ограничения Capacity и MaxBytes делятся между сегментами поровну.
+func NewShardedCache(cfg Config) OrderCache {
+	n := cfg.Shards
+	if n <= 0 {
+		n = DefaultShards
+	}
+	n = 1 << bits.Len(uint(n-1))
+
+	c := &shardedCache{
+		seed:     maphash.MakeSeed(),
+		shards:   make([]*clockShard, n),
+		tracks:   make([]*trackShard, n),
+		mask:     uint64(n - 1),
+		ttl:      cfg.TTL,
+		onEvict:  cfg.OnEvict,
+		capacity: cfg.Capacity,
+		maxBytes: cfg.MaxBytes,
+	}
+	for i := range c.shards {
+		c.shards[i] = &clockShard{
+			entries:  make(map[string]*clockEntry),
+			capacity: ceilDiv(cfg.Capacity, n),
+			maxBytes: int64(ceilDiv(int(cfg.MaxBytes), n)),
+		}
+		c.tracks[i] = &trackShard{m: make(map[string]string)}
+	}
+	return c
+}
+
+func ceilDiv(a, b int) int {
+	if a <= 0 {
+		return 0
+	}
+	return (a + b - 1) / b
+}
+
+func (c *shardedCache) shard(key string) *clockShard {
+	return c.shards[maphash.String(c.seed, key)&c.mask]
+}
+
+func (c *shardedCache) trackShard(track string) *trackShard {
+	return c.tracks[maphash.String(c.seed, track)&c.mask]
+}
+
+// Add добавляет заказ в кэш. Заказ, который больше бюджета памяти сегмента, не кэшируется.
+func (c *shardedCache) Add(key string, order *model.Order) {
+	s := c.shard(key)
+	size := EstimateSize(order)
+	if s.maxBytes > 0 && size > s.maxBytes {
+		return
+	}
+
+	var expiresAt int64
+	if c.ttl > 0 {
+		expiresAt = time.Now().Add(c.ttl).UnixNano()
+	}
+
+	s.mu.Lock()
+	var removed []evicted
+	e, ok := s.entries[key]
+	if ok {
+		c.unindex(key, e.value)
+		s.bytes += size - e.size
+		e.value = order
+		e.size = size
+		e.expiresAt = expiresAt
+	} else {
+		for s.capacity > 0 && len(s.ring) >= s.capacity {
+			removed = append(removed, c.evict(s, EvictCapacity, nil))
+		}
+		e = &clockEntry{key: key, value: order, size: size, expiresAt: expiresAt, slot: len(s.ring)}
+		s.ring = append(s.ring, e)
+		s.entries[key] = e
+		s.bytes += size
+	}
+	e.referenced.Store(true)
+	// Бюджет памяти освобождается за счёт других записей: только что добавленная
+	// запись не вытесняется.
+	for s.maxBytes > 0 && s.bytes > s.maxBytes && len(s.ring) > 1 {
+		removed = append(removed, c.evict(s, EvictBytes, e))
+	}
+	if s.entries[key] == e {
+		c.index(key, order)
+	}
+	s.mu.Unlock()
+
+	c.notify(removed)
+}
+
+// Get извлекает заказ из кэша под разделяемой блокировкой сегмента.
+func (c *shardedCache) Get(key string) (*model.Order, bool) {
+	s := c.shard(key)
+
+	s.mu.RLock()
+	e, ok := s.entries[key]
+	if !ok {
+		s.mu.RUnlock()
+		s.misses.Add(1)
+		return nil, false
+	}
+	if e.expiresAt == 0 || time.Now().UnixNano() < e.expiresAt {
+		e.referenced.Store(true)
+		order := e.value
+		s.mu.RUnlock()
+		s.hits.Add(1)
+		return order, true
+	}
+	s.mu.RUnlock()
+
+	// Запись просрочена: удаляем под эксклюзивной блокировкой, если её не успели обновить.
+	s.misses.Add(1)
+	s.mu.Lock()
+	var removed []evicted
+	if cur, ok := s.entries[key]; ok && cur == e && cur.expiresAt != 0 && time.Now().UnixNano() >= cur.expiresAt {
+		c.remove(s, e)
+		s.expirations.Add(1)
+		removed = append(removed, evicted{item: &cacheItem{key: e.key, value: e.value, size: e.size}, reason: EvictExpired})
+	}
+	s.mu.Unlock()
+
+	c.notify(removed)
+	return nil, false
+}
+
+// GetByTrackNumber извлекает заказ из кэша по трек-номеру.
+func (c *shardedCache) GetByTrackNumber(trackNumber string) (*model.Order, bool) {
+	ts := c.trackShard(trackNumber)
+	ts.mu.RLock()
+	key, ok := ts.m[trackNumber]
+	ts.mu.RUnlock()
+	if !ok {
+		c.shard(trackNumber).misses.Add(1)
+		return nil, false
+	}
+
+	order, ok := c.Get(key)
+	if !ok || order.TrackNumber != trackNumber {
+		return nil, false
+	}
+	return order, true
+}
+
+// Remove удаляет заказ из кэша. OnEvict не вызывается: удаление явное.
+func (c *shardedCache) Remove(key string) {
+	s := c.shard(key)
+	s.mu.Lock()
+	if e, ok := s.entries[key]; ok {
+		c.remove(s, e)
+	}
+	s.mu.Unlock()
+}
+
+// Purge удаляет все заказы из всех сегментов, счётчики сохраняются.
+func (c *shardedCache) Purge() {
+	for _, s := range c.shards {
+		s.mu.Lock()
+		s.entries = make(map[string]*clockEntry)
+		clear(s.ring)
+		s.ring = s.ring[:0]
+		s.hand = 0
+		s.bytes = 0
+		s.mu.Unlock()
+	}
+	for _, ts := range c.tracks {
+		ts.mu.Lock()
+		ts.m = make(map[string]string)
+		ts.mu.Unlock()
+	}
+}
+
+// Stats суммирует счётчики всех сегментов.
+func (c *shardedCache) Stats() Stats {
+	st := Stats{Capacity: c.capacity, MaxBytes: c.maxBytes}
+	for _, s := range c.shards {
+		st.Hits += s.hits.Load()
+		st.Misses += s.misses.Load()
+		st.Evictions += s.evictions.Load()
+		st.Expirations += s.expirations.Load()
+
+		s.mu.RLock()
+		st.Size += len(s.ring)
+		st.Bytes += s.bytes
+		s.mu.RUnlock()
+	}
+	return st
+}
+
+// evict выбирает жертву по CLOCK: стрелка обходит кольцо, снимая бит обращения,
+// и вытесняет первую запись без него, кроме keep. В кольце должна быть хотя бы одна
+// запись помимо keep. Выполняется под блокировкой сегмента.
+func (c *shardedCache) evict(s *clockShard, reason EvictReason, keep *clockEntry) evicted {
+	for {
+		if s.hand >= len(s.ring) {
+			s.hand = 0
+		}
+		e := s.ring[s.hand]
+		if e == keep || e.referenced.CompareAndSwap(true, false) {
+			s.hand++
+			continue
+		}
+		c.remove(s, e)
+		s.evictions.Add(1)
+		return evicted{item: &cacheItem{key: e.key, value: e.value, size: e.size}, reason: reason}
+	}
+}
+
+// remove удаляет запись из сегмента: последняя запись кольца переносится на её место.
+func (c *shardedCache) remove(s *clockShard, e *clockEntry) {
+	last := len(s.ring) - 1
+	moved := s.ring[last]
+	s.ring[e.slot] = moved
+	moved.slot = e.slot
+	s.ring[last] = nil
+	s.ring = s.ring[:last]
+
+	delete(s.entries, e.key)
+	s.bytes -= e.size
+	c.unindex(e.key, e.value)
+}
+
+func (c *shardedCache) notify(removed []evicted) {
+	if c.onEvict == nil {
+		return
+	}
+	for _, e := range removed {
+		c.onEvict(e.item.key, e.item.value, e.reason)
+	}
+}
+
+func (c *shardedCache) index(key string, order *model.Order) {
+	if order == nil || order.TrackNumber == "" {
+		return
+	}
+	ts := c.trackShard(order.TrackNumber)
+	ts.mu.Lock()
+	ts.m[order.TrackNumber] = key
+	ts.mu.Unlock()
+}
+
+// unindex удаляет вторичный ключ, только если он всё ещё указывает на key.
+func (c *shardedCache) unindex(key string, order *model.Order) {
+	if order == nil || order.TrackNumber == "" {
+		return
+	}
+	ts := c.trackShard(order.TrackNumber)
+	ts.mu.Lock()
+	if ts.m[order.TrackNumber] == key {
+		delete(ts.m, order.TrackNumber)
+	}
+	ts.mu.Unlock()
+}
@@ -0,0 +1,187 @@
+package cache
+
+import (
+	"L0_project/internal/model"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// implementations — реализации OrderCache, которые сравниваются под параллельной нагрузкой.
+var implementations = []string{ImplLRU, ImplSharded}
+
+func newCache(t testing.TB, impl string, cfg Config) OrderCache {
+	t.Helper()
+	c, err := New(impl, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func testOrder(i int) *model.Order {
+	uid := "order-" + strconv.Itoa(i)
+	return &model.Order{
+		OrderUID:    uid,
+		TrackNumber: "TRACK-" + strconv.Itoa(i),
+		Entry:       "WBIL",
+		Delivery:    model.Delivery{Name: "Test Testov", City: "Kiryat Mozkin"},
+		Payment:     model.Payment{Transaction: uid, Currency: "USD", Amount: 1817},
+		Items:       []model.Item{{ChrtID: i, TrackNumber: "TRACK-" + strconv.Itoa(i), Name: "Mascaras", Brand: "Vivienne Sabo"}},
+		Locale:      "en",
+		CustomerID:  "test",
+		DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+	}
+}
+
+func TestCacheConcurrentAccess(t *testing.T) {
+	const (
+		workers = 8
+		keys    = 256
+		ops     = 2000
+	)
+	for _, impl := range implementations {
+		t.Run(impl, func(t *testing.T) {
+			var evictions atomic.Int64
+			c := newCache(t, impl, Config{
+				Capacity: keys / 2,
+				TTL:      time.Minute,
+				OnEvict:  func(string, *model.Order, EvictReason) { evictions.Add(1) },
+			})
+
+			var wg sync.WaitGroup
+			for w := range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := range ops {
+						n := (w*ops + i) % keys
+						order := testOrder(n)
+						switch i % 4 {
+						case 0:
+							c.Add(order.OrderUID, order)
+						case 1:
+							if got, ok := c.Get(order.OrderUID); ok && got.OrderUID != order.OrderUID {
+								t.Errorf("Get(%s) вернул заказ %s", order.OrderUID, got.OrderUID)
+							}
+						case 2:
+							if got, ok := c.GetByTrackNumber(order.TrackNumber); ok && got.TrackNumber != order.TrackNumber {
+								t.Errorf("GetByTrackNumber(%s) вернул заказ с трек-номером %s", order.TrackNumber, got.TrackNumber)
+							}
+						case 3:
+							c.Remove(order.OrderUID)
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			st := c.Stats()
+			if st.Size > keys/2+DefaultShards {
+				t.Errorf("Size = %d, ожидалось не больше %d", st.Size, keys/2+DefaultShards)
+			}
+			if int64(st.Evictions) != evictions.Load() {
+				t.Errorf("Evictions = %d, OnEvict вызван %d раз", st.Evictions, evictions.Load())
+			}
+			if st.Hits+st.Misses == 0 {
+				t.Error("счётчики обращений не изменились")
+			}
+		})
+	}
+}
+
+func TestCacheRemove(t *testing.T) {
+	for _, impl := range implementations {
+		t.Run(impl, func(t *testing.T) {
+			c := newCache(t, impl, Config{})
+			order := testOrder(1)
+			c.Add(order.OrderUID, order)
+			c.Remove(order.OrderUID)
+
+			if _, ok := c.Get(order.OrderUID); ok {
+				t.Error("заказ найден после Remove")
+			}
+			if _, ok := c.GetByTrackNumber(order.TrackNumber); ok {
+				t.Error("заказ найден по трек-номеру после Remove")
+			}
+			if st := c.Stats(); st.Size != 0 || st.Bytes != 0 {
+				t.Errorf("после Remove Size = %d, Bytes = %d", st.Size, st.Bytes)
+			}
+		})
+	}
+}
+
+func TestShardedCacheKeepsAddedOrderOverByteBudget(t *testing.T) {
+	size := EstimateSize(testOrder(0))
+	c := NewShardedCache(Config{Shards: 1, MaxBytes: 3*size + size/2})
+
+	for i := range 20 {
+		order := testOrder(i)
+		c.Add(order.OrderUID, order)
+
+		if _, ok := c.Get(order.OrderUID); !ok {
+			t.Fatalf("заказ %s вытеснен сразу после добавления", order.OrderUID)
+		}
+		if _, ok := c.GetByTrackNumber(order.TrackNumber); !ok {
+			t.Fatalf("заказ %s не найден по трек-номеру сразу после добавления", order.OrderUID)
+		}
+	}
+
+	sc := c.(*shardedCache)
+	for track, key := range sc.tracks[0].m {
+		if _, ok := sc.shards[0].entries[key]; !ok {
+			t.Errorf("трек-номер %s указывает на отсутствующий заказ %s", track, key)
+		}
+	}
+	if st := c.Stats(); st.Bytes > 3*size+size/2 {
+		t.Errorf("Bytes = %d, бюджет %d", st.Bytes, 3*size+size/2)
+	}
+}
+
+func BenchmarkParallelGet(b *testing.B) {
+	const keys = 1024
+	for _, impl := range implementations {
+		b.Run(impl, func(b *testing.B) {
+			c := newCache(b, impl, Config{Capacity: keys})
+			orders := make([]*model.Order, keys)
+			for i := range orders {
+				orders[i] = testOrder(i)
+				c.Add(orders[i].OrderUID, orders[i])
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					c.Get(orders[i%keys].OrderUID)
+					i++
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkParallelAdd(b *testing.B) {
+	const keys = 1024
+	for _, impl := range implementations {
+		b.Run(impl, func(b *testing.B) {
+			c := newCache(b, impl, Config{Capacity: keys / 2})
+			orders := make([]*model.Order, keys)
+			for i := range orders {
+				orders[i] = testOrder(i)
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					order := orders[i%keys]
+					c.Add(order.OrderUID, order)
+					i++
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package codec
+
+import (
+	"L0_project/internal/model"
+	_ "embed"
+	"fmt"
+	"time"
+
+	"github.com/hamba/avro/v2"
+)
+
+//go:embed order.avsc
+var orderSchemaJSON string
+
+// orderSchema разбирается при инициализации пакета: ошибка в order.avsc — ошибка сборки.
+var orderSchema = avro.MustParse(orderSchemaJSON)
+
+// Avro — бинарное кодирование записи Order из order.avsc без заголовка
+// и идентификатора схемы: обе стороны используют одну и ту же схему.
+var Avro Codec = avroCodec{}
+
+type avroCodec struct{}
+
+func (avroCodec) Name() string        { return "avro" }
+func (avroCodec) ContentType() string { return ContentTypeAvro }
+
+// avroOrder и вложенные структуры повторяют order.avsc.
+type avroOrder struct {
+	OrderUID          string       `avro:"order_uid"`
+	TrackNumber       string       `avro:"track_number"`
+	Entry             string       `avro:"entry"`
+	Delivery          avroDelivery `avro:"delivery"`
+	Payment           avroPayment  `avro:"payment"`
+	Items             []avroItem   `avro:"items"`
+	Locale            string       `avro:"locale"`
+	InternalSignature string       `avro:"internal_signature"`
+	CustomerID        string       `avro:"customer_id"`
+	DeliveryService   string       `avro:"delivery_service"`
+	Shardkey          string       `avro:"shardkey"`
+	SmID              int64        `avro:"sm_id"`
+	DateCreated       time.Time    `avro:"date_created"`
+	OofShard          string       `avro:"oof_shard"`
+	Status            string       `avro:"status"`
+}
+
+type avroDelivery struct {
+	Name    string `avro:"name"`
+	Phone   string `avro:"phone"`
+	Zip     string `avro:"zip"`
+	City    string `avro:"city"`
+	Address string `avro:"address"`
+	Region  string `avro:"region"`
+	Email   string `avro:"email"`
+}
+
+type avroPayment struct {
+	Transaction  string `avro:"transaction"`
+	RequestID    string `avro:"request_id"`
+	Currency     string `avro:"currency"`
+	Provider     string `avro:"provider"`
+	Amount       int64  `avro:"amount"`
+	PaymentDt    int64  `avro:"payment_dt"`
+	Bank         string `avro:"bank"`
+	DeliveryCost int64  `avro:"delivery_cost"`
+	GoodsTotal   int64  `avro:"goods_total"`
+	CustomFee    int64  `avro:"custom_fee"`
+}
+
+type avroItem struct {
+	ChrtID      int64  `avro:"chrt_id"`
+	TrackNumber string `avro:"track_number"`
+	Price       int64  `avro:"price"`
+	Rid         string `avro:"rid"`
+	Name        string `avro:"name"`
+	Sale        int64  `avro:"sale"`
+	Size        string `avro:"size"`
+	TotalPrice  int64  `avro:"total_price"`
+	NmID        int64  `avro:"nm_id"`
+	Brand       string `avro:"brand"`
+	Status      int64  `avro:"status"`
+}
+
+func (avroCodec) Marshal(order *model.Order) ([]byte, error) {
+	data, err := avro.Marshal(orderSchema, toAvro(order))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сериализовать заказ в Avro: %w", err)
+	}
+	return data, nil
+}
+
+func (avroCodec) Unmarshal(data []byte) (*model.Order, error) {
+	var o avroOrder
+	if err := avro.Unmarshal(orderSchema, data, &o); err != nil {
+		return nil, fmt.Errorf("не удалось разобрать заказ в Avro: %w", err)
+	}
+	return fromAvro(&o), nil
+}
+
+func toAvro(order *model.Order) *avroOrder {
+	d, p := &order.Delivery, &order.Payment
+	o := &avroOrder{
+		OrderUID:    order.OrderUID,
+		TrackNumber: order.TrackNumber,
+		Entry:       order.Entry,
+		Delivery: avroDelivery{
+			Name:    d.Name,
+			Phone:   d.Phone,
+			Zip:     d.Zip,
+			City:    d.City,
+			Address: d.Address,
+			Region:  d.Region,
+			Email:   d.Email,
+		},
+		Payment: avroPayment{
+			Transaction:  p.Transaction,
+			RequestID:    p.RequestID,
+			Currency:     p.Currency,
+			Provider:     p.Provider,
+			Amount:       int64(p.Amount),
+			PaymentDt:    p.PaymentDt,
+			Bank:         p.Bank,
+			DeliveryCost: int64(p.DeliveryCost),
+			GoodsTotal:   int64(p.GoodsTotal),
+			CustomFee:    int64(p.CustomFee),
+		},
+		Items:             make([]avroItem, len(order.Items)),
+		Locale:            order.Locale,
+		InternalSignature: order.InternalSignature,
+		CustomerID:        order.CustomerID,
+		DeliveryService:   order.DeliveryService,
+		Shardkey:          order.Shardkey,
+		SmID:              int64(order.SmID),
+		DateCreated:       order.DateCreated,
+		OofShard:          order.OofShard,
+		Status:            string(order.Status),
+	}
+	for i, it := range order.Items {
+		o.Items[i] = avroItem{
+			ChrtID:      int64(it.ChrtID),
+			TrackNumber: it.TrackNumber,
+			Price:       int64(it.Price),
+			Rid:         it.Rid,
+			Name:        it.Name,
+			Sale:        int64(it.Sale),
+			Size:        it.Size,
+			TotalPrice:  int64(it.TotalPrice),
+			NmID:        int64(it.NmID),
+			Brand:       it.Brand,
+			Status:      int64(it.Status),
+		}
+	}
+	return o
+}
+
+func fromAvro(o *avroOrder) *model.Order {
+	d, p := &o.Delivery, &o.Payment
+	order := &model.Order{
+		OrderUID:    o.OrderUID,
+		TrackNumber: o.TrackNumber,
+		Entry:       o.Entry,
+		Delivery: model.Delivery{
+			Name:    d.Name,
+			Phone:   d.Phone,
+			Zip:     d.Zip,
+			City:    d.City,
+			Address: d.Address,
+			Region:  d.Region,
+			Email:   d.Email,
+		},
+		Payment: model.Payment{
+			Transaction:  p.Transaction,
+			RequestID:    p.RequestID,
+			Currency:     p.Currency,
+			Provider:     p.Provider,
+			Amount:       int(p.Amount),
+			PaymentDt:    p.PaymentDt,
+			Bank:         p.Bank,
+			DeliveryCost: int(p.DeliveryCost),
+			GoodsTotal:   int(p.GoodsTotal),
+			CustomFee:    int(p.CustomFee),
+		},
+		Locale:            o.Locale,
+		InternalSignature: o.InternalSignature,
+		CustomerID:        o.CustomerID,
+		DeliveryService:   o.DeliveryService,
+		Shardkey:          o.Shardkey,
+		SmID:              int(o.SmID),
+		DateCreated:       o.DateCreated,
+		OofShard:          o.OofShard,
+		Status:            model.OrderStatus(o.Status),
+	}
+	if len(o.Items) > 0 {
+		order.Items = make([]model.Item, len(o.Items))
+	}
+	for i, it := range o.Items {
+		order.Items[i] = model.Item{
+			ChrtID:      int(it.ChrtID),
+			TrackNumber: it.TrackNumber,
+			Price:       int(it.Price),
+			Rid:         it.Rid,
+			Name:        it.Name,
+			Sale:        int(it.Sale),
+			Size:        it.Size,
+			TotalPrice:  int(it.TotalPrice),
+			NmID:        int(it.NmID),
+			Brand:       it.Brand,
+			Status:      int(it.Status),
+		}
+	}
+	return order
+}
@@ -0,0 +1,78 @@
+// Package codec сериализует заказ в форматах передачи: JSON, Protobuf и Avro.
+// Схемы Protobuf и Avro лежат рядом с кодом (order.proto, order.avsc)
+// и повторяют model.Order.
+package codec
+
+import (
+	"L0_project/internal/model"
+	"errors"
+	"fmt"
+	"mime"
+
+	"github.com/munnerz/goautoneg"
+)
+
+// Типы содержимого поддерживаемых форматов.
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeAvro     = "application/avro"
+)
+
+// ErrUnsupportedFormat возвращается для неизвестного формата или типа содержимого.
+var ErrUnsupportedFormat = errors.New("неподдерживаемый формат заказа")
+
+// Codec сериализует заказ в одном формате.
+type Codec interface {
+	// Name — короткое имя формата: json, protobuf или avro.
+	Name() string
+	ContentType() string
+	Marshal(order *model.Order) ([]byte, error)
+	Unmarshal(data []byte) (*model.Order, error)
+}
+
+// Порядок важен для Negotiate: при равном приоритете в Accept выбирается первый.
+var codecs = []Codec{JSON, Protobuf, Avro}
+
+// ByName возвращает кодек по имени формата.
+func ByName(name string) (Codec, error) {
+	for _, c := range codecs {
+		if c.Name() == name {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, name)
+}
+
+// ByContentType возвращает кодек по значению Content-Type; параметры (charset и т.п.) игнорируются.
+func ByContentType(contentType string) (Codec, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, contentType)
+	}
+	for _, c := range codecs {
+		if c.ContentType() == mediaType {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, contentType)
+}
+
+// Negotiate выбирает кодек по заголовку Accept. Пустой заголовок означает JSON;
+// false возвращается, если ни один формат не подходит.
+func Negotiate(accept string) (Codec, bool) {
+	if accept == "" {
+		return JSON, true
+	}
+	c, err := ByContentType(goautoneg.Negotiate(accept, ContentTypes()))
+	return c, err == nil
+}
+
+// ContentTypes возвращает типы содержимого всех форматов.
+func ContentTypes() []string {
+	types := make([]string, len(codecs))
+	for i, c := range codecs {
+		types[i] = c.ContentType()
+	}
+	return types
+}
@@ -0,0 +1,258 @@
+package codec
+
+import (
+	"L0_project/internal/model"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// testOrder возвращает заказ, в котором заполнены все поля схем.
+func testOrder() *model.Order {
+	return &model.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: model.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Payment: model.Payment{
+			Transaction: "b563feb7b2b84b6test", RequestID: "req-1", Currency: "USD", Provider: "wbpay",
+			Amount: 1817, PaymentDt: 1637907727, Bank: "alpha", DeliveryCost: 1500, GoodsTotal: 317, CustomFee: 7,
+		},
+		Items: []model.Item{
+			{ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, Rid: "ab4219087a764ae0btest", Name: "Mascaras",
+				Sale: 30, Size: "0", TotalPrice: 317, NmID: 2389212, Brand: "Vivienne Sabo", Status: 202},
+			{ChrtID: 1, TrackNumber: "WBILMTESTTRACK", Price: 1, Rid: "r2", Name: "Тушь", Sale: 1, Size: "L",
+				TotalPrice: 1, NmID: 2, Brand: "Бренд", Status: 200},
+		},
+		Locale:            "en",
+		InternalSignature: "sig",
+		CustomerID:        "test",
+		DeliveryService:   "meest",
+		Shardkey:          "9",
+		SmID:              99,
+		DateCreated:       time.Date(2021, 11, 26, 6, 22, 19, 123456789, time.UTC),
+		OofShard:          "1",
+		Status:            model.StatusCreated,
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	negative := testOrder()
+	negative.SmID, negative.Payment.PaymentDt, negative.Items[0].Status = -1, -1637907727, -202
+	negative.DateCreated = time.Date(1969, 7, 20, 20, 17, 40, 5000, time.UTC)
+
+	orders := map[string]*model.Order{
+		"заполненный":           testOrder(),
+		"отрицательные числа":   negative,
+		"пустой":                {},
+		"без товаров и статуса": {OrderUID: "uid", DateCreated: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range codecs {
+		for name, order := range orders {
+			t.Run(c.Name()+"/"+name, func(t *testing.T) {
+				data, err := c.Marshal(order)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := c.Unmarshal(data)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				want := *order
+				if c == Avro {
+					// timestamp-micros отбрасывает наносекунды.
+					want.DateCreated = want.DateCreated.Truncate(time.Microsecond)
+				}
+				if !got.DateCreated.Equal(want.DateCreated) {
+					t.Errorf("date_created = %v, ожидалось %v", got.DateCreated, want.DateCreated)
+				}
+				got.DateCreated, want.DateCreated = time.Time{}, time.Time{}
+				if !reflect.DeepEqual(got, &want) {
+					t.Errorf("после %s:\n%+v\nожидалось\n%+v", c.Name(), got, &want)
+				}
+			})
+		}
+	}
+}
+
+func TestAvroTruncatesToMicroseconds(t *testing.T) {
+	order := testOrder()
+	data, err := Avro.Marshal(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Avro.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2021, 11, 26, 6, 22, 19, 123456000, time.UTC); !got.DateCreated.Equal(want) {
+		t.Errorf("date_created = %v, ожидалось %v", got.DateCreated, want)
+	}
+}
+
+// orderDescriptor собирает дескриптор l0.order.v1.Order из order.proto.
+func orderDescriptor(t *testing.T) protoreflect.MessageDescriptor {
+	t.Helper()
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{}),
+	}
+	files, err := compiler.Compile(context.Background(), "order.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd, err := protodesc.NewFile(protodesc.ToFileDescriptorProto(files[0]), protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := fd.Messages().ByName("Order")
+	if md == nil {
+		t.Fatal("в order.proto нет сообщения Order")
+	}
+	return md
+}
+
+// TestProtobufMatchesSchema разбирает вывод Protobuf.Marshal по схеме order.proto
+// и сверяет поля по именам с JSON того же заказа: номера полей и типы в protobuf.go
+// должны совпадать со схемой.
+func TestProtobufMatchesSchema(t *testing.T) {
+	md := orderDescriptor(t)
+	order := testOrder()
+
+	data, err := Protobuf.Marshal(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := dynamicpb.NewMessage(md)
+	if err := proto.Unmarshal(data, msg); err != nil {
+		t.Fatal(err)
+	}
+	if unknown := msg.GetUnknown(); len(unknown) > 0 {
+		t.Errorf("поля вне схемы: %x", unknown)
+	}
+
+	got := make(map[string]string)
+	flattenMessage(msg, "", got)
+	want := flattenJSON(t, order)
+	for key, v := range want {
+		if got[key] != v {
+			t.Errorf("%s = %q, ожидалось %q", key, got[key], v)
+		}
+	}
+	for key := range got {
+		if _, ok := want[key]; !ok {
+			t.Errorf("лишнее поле %s", key)
+		}
+	}
+
+	// Обратно: сообщение, закодированное по схеме, разбирается Protobuf.Unmarshal.
+	encoded, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := Protobuf.Unmarshal(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, order) {
+		t.Errorf("Protobuf.Unmarshal:\n%+v\nожидалось\n%+v", back, order)
+	}
+}
+
+// flattenMessage записывает поля сообщения в out по путям вида "items[0].price".
+// google.protobuf.Timestamp записывается как время в RFC 3339, как в JSON.
+func flattenMessage(msg protoreflect.Message, prefix string, out map[string]string) {
+	if msg.Descriptor().FullName() == "google.protobuf.Timestamp" {
+		fields := msg.Descriptor().Fields()
+		sec := msg.Get(fields.ByName("seconds")).Int()
+		nsec := msg.Get(fields.ByName("nanos")).Int()
+		out[prefix] = time.Unix(sec, nsec).UTC().Format(time.RFC3339Nano)
+		return
+	}
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		key := string(fd.Name())
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch {
+		case fd.IsList():
+			for i := range v.List().Len() {
+				flattenValue(fd, v.List().Get(i), fmt.Sprintf("%s[%d]", key, i), out)
+			}
+		default:
+			flattenValue(fd, v, key, out)
+		}
+		return true
+	})
+}
+
+func flattenValue(fd protoreflect.FieldDescriptor, v protoreflect.Value, key string, out map[string]string) {
+	switch fd.Kind() {
+	case protoreflect.MessageKind:
+		flattenMessage(v.Message(), key, out)
+	case protoreflect.StringKind:
+		out[key] = v.String()
+	default:
+		out[key] = strconv.FormatInt(v.Int(), 10)
+	}
+}
+
+// flattenJSON записывает поля JSON заказа в плоскую карту, как flattenMessage.
+// Нулевые значения пропускаются: proto3 их не передаёт.
+func flattenJSON(t *testing.T, order *model.Order) map[string]string {
+	t.Helper()
+	data, err := json.Marshal(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	out := make(map[string]string)
+	var walk func(v any, key string)
+	walk = func(v any, key string) {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, field := range v {
+				if key != "" {
+					k = key + "." + k
+				}
+				walk(field, k)
+			}
+		case []any:
+			for i, elem := range v {
+				walk(elem, fmt.Sprintf("%s[%d]", key, i))
+			}
+		case json.Number:
+			if v != "0" {
+				out[key] = v.String()
+			}
+		case string:
+			if v != "" {
+				out[key] = v
+			}
+		}
+	}
+	walk(v, "")
+	return out
+}
@@ -0,0 +1,27 @@
+package codec
+
+import (
+	"L0_project/internal/model"
+	"encoding/json"
+	"fmt"
+)
+
+// JSON — формат model.Order с JSON-тегами модели.
+var JSON Codec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string        { return "json" }
+func (jsonCodec) ContentType() string { return ContentTypeJSON }
+
+func (jsonCodec) Marshal(order *model.Order) ([]byte, error) {
+	return json.Marshal(order)
+}
+
+func (jsonCodec) Unmarshal(data []byte) (*model.Order, error) {
+	var order model.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		return nil, fmt.Errorf("не удалось разобрать заказ в JSON: %w", err)
+	}
+	return &order, nil
+}
@@ -0,0 +1,326 @@
+package codec
+
+import (
+	"L0_project/internal/model"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Protobuf — формат сообщения Order из order.proto. Сериализация написана на protowire
+// без сгенерированного кода: схема небольшая, а генератор не нужен в сборке.
+var Protobuf Codec = protobufCodec{}
+
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string        { return "protobuf" }
+func (protobufCodec) ContentType() string { return ContentTypeProtobuf }
+
+func (protobufCodec) Marshal(order *model.Order) ([]byte, error) {
+	var b []byte
+	b = appendString(b, 1, order.OrderUID)
+	b = appendString(b, 2, order.TrackNumber)
+	b = appendString(b, 3, order.Entry)
+	b = appendMessage(b, 4, appendDelivery(nil, &order.Delivery))
+	b = appendMessage(b, 5, appendPayment(nil, &order.Payment))
+	for i := range order.Items {
+		b = appendMessage(b, 6, appendItem(nil, &order.Items[i]))
+	}
+	b = appendString(b, 7, order.Locale)
+	b = appendString(b, 8, order.InternalSignature)
+	b = appendString(b, 9, order.CustomerID)
+	b = appendString(b, 10, order.DeliveryService)
+	b = appendString(b, 11, order.Shardkey)
+	b = appendInt(b, 12, int64(order.SmID))
+	if !order.DateCreated.IsZero() {
+		b = appendMessage(b, 13, appendTimestamp(nil, order.DateCreated))
+	}
+	b = appendString(b, 14, order.OofShard)
+	b = appendString(b, 15, string(order.Status))
+	return b, nil
+}
+
+func appendDelivery(b []byte, d *model.Delivery) []byte {
+	b = appendString(b, 1, d.Name)
+	b = appendString(b, 2, d.Phone)
+	b = appendString(b, 3, d.Zip)
+	b = appendString(b, 4, d.City)
+	b = appendString(b, 5, d.Address)
+	b = appendString(b, 6, d.Region)
+	return appendString(b, 7, d.Email)
+}
+
+func appendPayment(b []byte, p *model.Payment) []byte {
+	b = appendString(b, 1, p.Transaction)
+	b = appendString(b, 2, p.RequestID)
+	b = appendString(b, 3, p.Currency)
+	b = appendString(b, 4, p.Provider)
+	b = appendInt(b, 5, int64(p.Amount))
+	b = appendInt(b, 6, p.PaymentDt)
+	b = appendString(b, 7, p.Bank)
+	b = appendInt(b, 8, int64(p.DeliveryCost))
+	b = appendInt(b, 9, int64(p.GoodsTotal))
+	return appendInt(b, 10, int64(p.CustomFee))
+}
+
+func appendItem(b []byte, it *model.Item) []byte {
+	b = appendInt(b, 1, int64(it.ChrtID))
+	b = appendString(b, 2, it.TrackNumber)
+	b = appendInt(b, 3, int64(it.Price))
+	b = appendString(b, 4, it.Rid)
+	b = appendString(b, 5, it.Name)
+	b = appendInt(b, 6, int64(it.Sale))
+	b = appendString(b, 7, it.Size)
+	b = appendInt(b, 8, int64(it.TotalPrice))
+	b = appendInt(b, 9, int64(it.NmID))
+	b = appendString(b, 10, it.Brand)
+	return appendInt(b, 11, int64(it.Status))
+}
+
+// appendTimestamp кодирует google.protobuf.Timestamp.
+func appendTimestamp(b []byte, t time.Time) []byte {
+	b = appendInt(b, 1, t.Unix())
+	return appendInt(b, 2, int64(t.Nanosecond()))
+}
+
+// Значения по умолчанию в proto3 не передаются.
+
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+func appendInt(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+func (protobufCodec) Unmarshal(data []byte) (*model.Order, error) {
+	var order model.Order
+	err := decodeFields(data, func(f field) error {
+		var err error
+		switch f.num {
+		case 1:
+			order.OrderUID, err = f.string()
+		case 2:
+			order.TrackNumber, err = f.string()
+		case 3:
+			order.Entry, err = f.string()
+		case 4:
+			err = f.message(func(b []byte) error { return decodeDelivery(b, &order.Delivery) })
+		case 5:
+			err = f.message(func(b []byte) error { return decodePayment(b, &order.Payment) })
+		case 6:
+			var it model.Item
+			err = f.message(func(b []byte) error { return decodeItem(b, &it) })
+			order.Items = append(order.Items, it)
+		case 7:
+			order.Locale, err = f.string()
+		case 8:
+			order.InternalSignature, err = f.string()
+		case 9:
+			order.CustomerID, err = f.string()
+		case 10:
+			order.DeliveryService, err = f.string()
+		case 11:
+			order.Shardkey, err = f.string()
+		case 12:
+			order.SmID, err = f.int()
+		case 13:
+			err = f.message(func(b []byte) error { return decodeTimestamp(b, &order.DateCreated) })
+		case 14:
+			order.OofShard, err = f.string()
+		case 15:
+			var s string
+			s, err = f.string()
+			order.Status = model.OrderStatus(s)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось разобрать заказ в Protobuf: %w", err)
+	}
+	return &order, nil
+}
+
+func decodeDelivery(b []byte, d *model.Delivery) error {
+	return decodeFields(b, func(f field) error {
+		var err error
+		switch f.num {
+		case 1:
+			d.Name, err = f.string()
+		case 2:
+			d.Phone, err = f.string()
+		case 3:
+			d.Zip, err = f.string()
+		case 4:
+			d.City, err = f.string()
+		case 5:
+			d.Address, err = f.string()
+		case 6:
+			d.Region, err = f.string()
+		case 7:
+			d.Email, err = f.string()
+		}
+		return err
+	})
+}
+
+func decodePayment(b []byte, p *model.Payment) error {
+	return decodeFields(b, func(f field) error {
+		var err error
+		switch f.num {
+		case 1:
+			p.Transaction, err = f.string()
+		case 2:
+			p.RequestID, err = f.string()
+		case 3:
+			p.Currency, err = f.string()
+		case 4:
+			p.Provider, err = f.string()
+		case 5:
+			p.Amount, err = f.int()
+		case 6:
+			p.PaymentDt, err = f.int64()
+		case 7:
+			p.Bank, err = f.string()
+		case 8:
+			p.DeliveryCost, err = f.int()
+		case 9:
+			p.GoodsTotal, err = f.int()
+		case 10:
+			p.CustomFee, err = f.int()
+		}
+		return err
+	})
+}
+
+func decodeItem(b []byte, it *model.Item) error {
+	return decodeFields(b, func(f field) error {
+		var err error
+		switch f.num {
+		case 1:
+			it.ChrtID, err = f.int()
+		case 2:
+			it.TrackNumber, err = f.string()
+		case 3:
+			it.Price, err = f.int()
+		case 4:
+			it.Rid, err = f.string()
+		case 5:
+			it.Name, err = f.string()
+		case 6:
+			it.Sale, err = f.int()
+		case 7:
+			it.Size, err = f.string()
+		case 8:
+			it.TotalPrice, err = f.int()
+		case 9:
+			it.NmID, err = f.int()
+		case 10:
+			it.Brand, err = f.string()
+		case 11:
+			it.Status, err = f.int()
+		}
+		return err
+	})
+}
+
+func decodeTimestamp(b []byte, t *time.Time) error {
+	var sec, nsec int64
+	err := decodeFields(b, func(f field) error {
+		var err error
+		switch f.num {
+		case 1:
+			sec, err = f.int64()
+		case 2:
+			nsec, err = f.int64()
+		}
+		return err
+	})
+	*t = time.Unix(sec, nsec).UTC()
+	return err
+}
+
+// field — поле сообщения: значение varint или содержимое length-delimited поля.
+type field struct {
+	num   protowire.Number
+	typ   protowire.Type
+	value uint64
+	bytes []byte
+}
+
+// decodeFields разбирает поля сообщения по порядку и передаёт их fn.
+// Поля других типов (fixed32/64, group) пропускаются: в схеме их нет.
+func decodeFields(b []byte, fn func(field) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		f := field{num: num, typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.value, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return fmt.Errorf("поле %d: %w", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		if typ != protowire.VarintType && typ != protowire.BytesType {
+			continue
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f field) expect(typ protowire.Type) error {
+	if f.typ != typ {
+		return fmt.Errorf("поле %d: неожиданный тип %d", f.num, f.typ)
+	}
+	return nil
+}
+
+func (f field) string() (string, error) {
+	return string(f.bytes), f.expect(protowire.BytesType)
+}
+
+func (f field) int64() (int64, error) {
+	return int64(f.value), f.expect(protowire.VarintType)
+}
+
+func (f field) int() (int, error) {
+	return int(int64(f.value)), f.expect(protowire.VarintType)
+}
+
+func (f field) message(decode func([]byte) error) error {
+	if err := f.expect(protowire.BytesType); err != nil {
+		return err
+	}
+	if err := decode(f.bytes); err != nil {
+		return fmt.Errorf("поле %d: %w", f.num, err)
+	}
+	return nil
+}
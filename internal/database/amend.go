@@ -0,0 +1,289 @@
+package database
+
+import (
+	"L0_project/internal/metrics"
+	"L0_project/internal/model"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrVersionMismatch возвращается, если заказ изменился после того, как клиент
+// получил его версию.
+var ErrVersionMismatch = errors.New("версия заказа не совпадает с текущей")
+
+// ErrOrderAmended возвращается, если из Kafka пришло новое содержимое заказа, который
+// изменён через API: применить его значило бы молча отменить изменение.
+var ErrOrderAmended = errors.New("содержимое заказа изменено через API")
+
+// Amendment — изменение заказа через API.
+type Amendment struct {
+	// Action — действие для журнала изменений, см. model.Audit*.
+	Action string
+	Actor  string
+	Reason string
+	// Apply изменяет копию текущего заказа. Ошибка Apply отменяет изменение
+	// и возвращается без обёртки.
+	Apply func(order *model.Order) error
+}
+
+// AmendOrder применяет change к заказу версии version: изменения содержимого
+// сохраняются так же, как при повторной доставке заказа, но последним принятым
+// содержимым (ingested_hash) не становятся, поэтому новое содержимое из Kafka
+// отклоняется с ErrOrderAmended, а не отменяет изменение. Смена статуса проверяется
+// конечным автоматом и попадает в историю статусов с источником API. Версия заказа
+// увеличивается, а в журнал order_audit записываются изменившиеся поля.
+// Если change ничего не изменил, возвращается текущий заказ без новой версии.
+// Версия, отличная от текущей, возвращает ошибку, оборачивающую ErrVersionMismatch.
+func (s *Storage) AmendOrder(ctx context.Context, orderUID string, version int, change Amendment) (_ *model.Order, err error) {
+	defer metrics.ObserveDBQuery("AmendOrder", time.Now(), &err)
+	defer classify(&err)
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Та же блокировка, что и при сохранении заказа из Kafka.
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, orderUID); err != nil {
+		return nil, fmt.Errorf("не удалось заблокировать заказ %s: %w", orderUID, err)
+	}
+
+	var current struct {
+		DeliveryID int `db:"delivery_id"`
+		PaymentID  int `db:"payment_id"`
+		Version    int `db:"version"`
+	}
+	err = tx.GetContext(ctx, &current, `SELECT delivery_id, payment_id, version FROM orders WHERE order_uid = $1 FOR UPDATE`, orderUID)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить заказ %s: %w", orderUID, err)
+	}
+	if current.Version != version {
+		return nil, fmt.Errorf("заказ %s: %w: ожидалась %d, текущая %d", orderUID, ErrVersionMismatch, version, current.Version)
+	}
+
+	orders, err := queryOrders(ctx, tx, orderSelect+` WHERE o.order_uid = $1`, orderUID)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить заказ %s: %w", orderUID, err)
+	}
+	if len(orders) == 0 {
+		return nil, fmt.Errorf("не удалось получить заказ %s: %w", orderUID, sql.ErrNoRows)
+	}
+	before := &orders[0]
+
+	after, changed, err := amend(before, change)
+	if err != nil || !changed {
+		return before, err
+	}
+	after.Version = current.Version + 1
+
+	beforeHash, err := orderHash(before)
+	if err != nil {
+		return nil, err
+	}
+	afterHash, err := orderHash(after)
+	if err != nil {
+		return nil, err
+	}
+	if afterHash != beforeHash {
+		if err := updateOrder(ctx, tx, after, afterHash, current.DeliveryID, current.PaymentID, false); err != nil {
+			return nil, err
+		}
+	}
+	if after.Status != before.Status {
+		_, err := tx.ExecContext(ctx, `UPDATE orders SET status = $2, version = $3, updated_at = now() WHERE order_uid = $1`, orderUID, after.Status, after.Version)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось обновить статус заказа %s: %w", orderUID, err)
+		}
+		if err := insertStatusChange(ctx, tx, model.StatusChange{OrderUID: orderUID, From: before.Status, To: after.Status, Source: model.StatusSourceAPI}); err != nil {
+			return nil, err
+		}
+	}
+
+	entry, err := auditEntry(before, after, change)
+	if err != nil {
+		return nil, err
+	}
+	if err := insertAudit(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+	if err := notifyOrderChanged(ctx, tx, orderUID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+	}
+	return after, nil
+}
+
+// GetOrderAudit возвращает журнал изменений заказа в хронологическом порядке.
+func (s *Storage) GetOrderAudit(ctx context.Context, orderUID string) (_ []model.AuditEntry, err error) {
+	defer metrics.ObserveDBQuery("GetOrderAudit", time.Now(), &err)
+	defer classify(&err)
+
+	var rows []struct {
+		Version   int       `db:"version"`
+		Action    string    `db:"action"`
+		Actor     string    `db:"actor"`
+		Reason    string    `db:"reason"`
+		Before    string    `db:"before"`
+		After     string    `db:"after"`
+		ChangedAt time.Time `db:"changed_at"`
+	}
+	query := `SELECT version, action, actor, reason, before::text AS before, after::text AS after, changed_at
+              FROM order_audit
+              WHERE order_uid = $1
+              ORDER BY id`
+	if err := s.db.SelectContext(ctx, &rows, query, orderUID); err != nil {
+		return nil, fmt.Errorf("не удалось получить журнал изменений заказа %s: %w", orderUID, err)
+	}
+
+	if len(rows) == 0 {
+		// Пустой журнал у заказа, который ещё не меняли через API, — не ошибка.
+		var exists bool
+		if err := s.db.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM orders WHERE order_uid = $1)`, orderUID); err != nil {
+			return nil, fmt.Errorf("не удалось проверить наличие заказа %s: %w", orderUID, err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("не удалось получить журнал изменений заказа %s: %w", orderUID, sql.ErrNoRows)
+		}
+	}
+
+	entries := make([]model.AuditEntry, len(rows))
+	for i, row := range rows {
+		entries[i] = model.AuditEntry{
+			OrderUID:  orderUID,
+			Version:   row.Version,
+			Action:    row.Action,
+			Actor:     row.Actor,
+			Reason:    row.Reason,
+			Before:    json.RawMessage(row.Before),
+			After:     json.RawMessage(row.After),
+			ChangedAt: row.ChangedAt,
+		}
+	}
+	return entries, nil
+}
+
+// amend применяет change к копии order. changed ложно, если заказ не изменился.
+// Смена статуса проверяется конечным автоматом.
+func amend(order *model.Order, change Amendment) (_ *model.Order, changed bool, err error) {
+	after := *order
+	after.Items = slices.Clone(order.Items)
+	if err := change.Apply(&after); err != nil {
+		return nil, false, err
+	}
+	if after.OrderUID != order.OrderUID {
+		return nil, false, fmt.Errorf("заказ %s: идентификатор заказа нельзя изменить", order.OrderUID)
+	}
+	if after.Status != order.Status {
+		if err := order.Status.Transition(after.Status); err != nil {
+			return nil, false, fmt.Errorf("заказ %s: %w", order.OrderUID, err)
+		}
+	}
+	after.Version = order.Version
+	return &after, !reflect.DeepEqual(*order, after), nil
+}
+
+// auditEntry составляет запись журнала из изменившихся полей заказа.
+func auditEntry(before, after *model.Order, change Amendment) (model.AuditEntry, error) {
+	b, a, err := orderDiff(before, after)
+	if err != nil {
+		return model.AuditEntry{}, fmt.Errorf("не удалось сравнить версии заказа %s: %w", before.OrderUID, err)
+	}
+	return model.AuditEntry{
+		OrderUID: after.OrderUID,
+		Version:  after.Version,
+		Action:   change.Action,
+		Actor:    change.Actor,
+		Reason:   change.Reason,
+		Before:   b,
+		After:    a,
+	}, nil
+}
+
+// orderDiff возвращает JSON изменившихся полей заказа до и после изменения.
+// Вложенные объекты (delivery, payment) сравниваются по полям, списки — целиком.
+// Версия в разницу не попадает: она записывается отдельно.
+func orderDiff(before, after *model.Order) (json.RawMessage, json.RawMessage, error) {
+	b, err := jsonObject(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := jsonObject(after)
+	if err != nil {
+		return nil, nil, err
+	}
+	delete(b, "version")
+	delete(a, "version")
+	db, da := diffObjects(b, a)
+
+	bj, err := json.Marshal(db)
+	if err != nil {
+		return nil, nil, err
+	}
+	aj, err := json.Marshal(da)
+	if err != nil {
+		return nil, nil, err
+	}
+	return bj, aj, nil
+}
+
+func jsonObject(v any) (map[string]any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// diffObjects оставляет в before и after только различающиеся ключи. Ключ,
+// которого нет с одной из сторон, попадает на эту сторону как null.
+func diffObjects(before, after map[string]any) (map[string]any, map[string]any) {
+	db, da := map[string]any{}, map[string]any{}
+	for key, bv := range before {
+		av, ok := after[key]
+		if !ok {
+			db[key], da[key] = bv, nil
+			continue
+		}
+		if reflect.DeepEqual(bv, av) {
+			continue
+		}
+		bo, bok := bv.(map[string]any)
+		ao, aok := av.(map[string]any)
+		if bok && aok {
+			db[key], da[key] = diffObjects(bo, ao)
+			continue
+		}
+		db[key], da[key] = bv, av
+	}
+	for key, av := range after {
+		if _, ok := before[key]; !ok {
+			db[key], da[key] = nil, av
+		}
+	}
+	return db, da
+}
+
+func insertAudit(ctx context.Context, tx *sqlx.Tx, entry model.AuditEntry) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO order_audit (order_uid, version, action, actor, reason, before, after) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		entry.OrderUID, entry.Version, entry.Action, entry.Actor, entry.Reason, string(entry.Before), string(entry.After))
+	if err != nil {
+		return fmt.Errorf("не удалось записать журнал изменений заказа %s: %w", entry.OrderUID, err)
+	}
+	return nil
+}
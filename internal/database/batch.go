@@ -0,0 +1,191 @@
+package database
+
+import (
+	"L0_project/internal/metrics"
+	"L0_project/internal/model"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// SaveOrders сохраняет пакет заказов в одной транзакции и возвращает результат для
+// каждого заказа в порядке orders. Новые заказы, которые встречаются в пакете один раз,
+// записываются через COPY; обновления и повторы одного order_uid внутри пакета
+// сохраняются по одному, как в SaveOrder. Если хотя бы один заказ не сохранён,
+// транзакция откатывается целиком.
+func (s *Storage) SaveOrders(ctx context.Context, orders []*model.Order) (_ []SaveResult, err error) {
+	defer metrics.ObserveDBQuery("SaveOrders", time.Now(), &err)
+	defer classify(&err)
+
+	if len(orders) == 0 {
+		return nil, nil
+	}
+
+	hashes := make([]string, len(orders))
+	count := make(map[string]int, len(orders))
+	for i, order := range orders {
+		if hashes[i], err = orderHash(order); err != nil {
+			return nil, err
+		}
+		count[order.OrderUID]++
+	}
+	uids := make([]string, 0, len(count))
+	for uid := range count {
+		uids = append(uids, uid)
+	}
+	// Блокировки берутся в одном порядке, чтобы параллельные пакеты не взаимоблокировались.
+	sort.Strings(uids)
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback()
+
+	lockQuery := `SELECT pg_advisory_xact_lock(hashtext(uid)) FROM (SELECT uid FROM unnest($1::text[]) AS uid ORDER BY uid) AS u`
+	if _, err := tx.ExecContext(ctx, lockQuery, pq.Array(uids)); err != nil {
+		return nil, fmt.Errorf("не удалось заблокировать заказы пакета: %w", err)
+	}
+
+	var existing []string
+	if err := tx.SelectContext(ctx, &existing, `SELECT order_uid FROM orders WHERE order_uid = ANY($1)`, pq.Array(uids)); err != nil {
+		return nil, fmt.Errorf("не удалось проверить наличие заказов пакета: %w", err)
+	}
+	exists := make(map[string]bool, len(existing))
+	for _, uid := range existing {
+		exists[uid] = true
+	}
+
+	results := make([]SaveResult, len(orders))
+	var bulk []int
+	for i, order := range orders {
+		if !exists[order.OrderUID] && count[order.OrderUID] == 1 {
+			bulk = append(bulk, i)
+		}
+	}
+	if err := copyOrders(ctx, tx, orders, hashes, bulk); err != nil {
+		return nil, err
+	}
+	for _, i := range bulk {
+		orders[i].Status, orders[i].Version = model.StatusCreated, 1
+		results[i] = SaveCreated
+	}
+
+	for i, order := range orders {
+		if results[i] != 0 {
+			continue
+		}
+		if results[i], err = saveOrderTx(ctx, tx, order, hashes[i], model.StatusSourceKafka); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+	}
+	return results, nil
+}
+
+// copyOrders записывает новые заказы orders[i], i из idx, через COPY. Идентификаторы
+// доставок и оплат выделяются из последовательностей заранее: COPY их не возвращает.
+func copyOrders(ctx context.Context, tx *sqlx.Tx, orders []*model.Order, hashes []string, idx []int) error {
+	if len(idx) == 0 {
+		return nil
+	}
+
+	deliveryIDs, err := nextIDs(ctx, tx, "deliveries", len(idx))
+	if err != nil {
+		return err
+	}
+	paymentIDs, err := nextIDs(ctx, tx, "payments", len(idx))
+	if err != nil {
+		return err
+	}
+
+	var deliveries, payments, rows, items, history, outbox [][]any
+	for j, i := range idx {
+		o := orders[i]
+		d, p := o.Delivery, o.Payment
+		deliveries = append(deliveries, []any{deliveryIDs[j], d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email})
+		payments = append(payments, []any{paymentIDs[j], p.Transaction, p.RequestID, p.Currency, p.Provider, p.Amount, p.PaymentDt, p.Bank, p.DeliveryCost, p.GoodsTotal, p.CustomFee})
+		rows = append(rows, []any{o.OrderUID, o.TrackNumber, o.Entry, deliveryIDs[j], paymentIDs[j], o.Locale, o.InternalSignature, o.CustomerID, o.DeliveryService, o.Shardkey, o.SmID, o.DateCreated, o.OofShard, hashes[i], model.StatusCreated, sourceAt(o), hashes[i]})
+		for _, item := range o.Items {
+			items = append(items, []any{o.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.Rid, item.Name, item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status})
+		}
+		history = append(history, []any{o.OrderUID, model.StatusCreated, model.StatusSourceKafka})
+
+		payload, err := orderAcceptedEvent(o)
+		if err != nil {
+			return err
+		}
+		outbox = append(outbox, []any{o.OrderUID, model.EventOrderAccepted, string(payload)})
+	}
+
+	tables := []struct {
+		name    string
+		columns []string
+		rows    [][]any
+	}{
+		{"deliveries", []string{"id", "name", "phone", "zip", "city", "address", "region", "email"}, deliveries},
+		{"payments", []string{"id", "transaction", "request_id", "currency", "provider", "amount", "payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee"}, payments},
+		{"orders", []string{"order_uid", "track_number", "entry", "delivery_id", "payment_id", "locale", "internal_signature", "customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard", "payload_hash", "status", "source_at", "ingested_hash"}, rows},
+		{"items", []string{"order_uid", "chrt_id", "track_number", "price", "rid", "name", "sale", "size", "total_price", "nm_id", "brand", "status"}, items},
+		{"order_status_history", []string{"order_uid", "to_status", "source"}, history},
+		{"outbox", []string{"aggregate_id", "event_type", "payload"}, outbox},
+	}
+	for _, t := range tables {
+		if err := copyIn(ctx, tx, t.name, t.columns, t.rows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextIDs выделяет n значений последовательности столбца id таблицы table.
+func nextIDs(ctx context.Context, tx *sqlx.Tx, table string, n int) ([]int, error) {
+	var ids []int
+	query := `SELECT nextval(pg_get_serial_sequence($1, 'id')) FROM generate_series(1, $2)`
+	if err := tx.SelectContext(ctx, &ids, query, table, n); err != nil {
+		return nil, fmt.Errorf("не удалось выделить идентификаторы %s: %w", table, err)
+	}
+	return ids, nil
+}
+
+// copyIn загружает строки в таблицу командой COPY FROM STDIN.
+func copyIn(ctx context.Context, tx *sqlx.Tx, table string, columns []string, rows [][]any) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(table, columns...))
+	if err != nil {
+		return fmt.Errorf("не удалось начать COPY в %s: %w", table, err)
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			return fmt.Errorf("не удалось передать строку COPY в %s: %w", table, err)
+		}
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return fmt.Errorf("не удалось выполнить COPY в %s: %w", table, err)
+	}
+	return nil
+}
+
+// IsDataError сообщает, что Postgres отклонил сами данные: нарушено ограничение
+// (класс 23, например повтор track_number) или значение некорректно (класс 22).
+// Повтор той же операции такую ошибку не исправит.
+func IsDataError(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		class := pqErr.Code.Class()
+		return class == "22" || class == "23"
+	}
+	return false
+}
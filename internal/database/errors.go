@@ -0,0 +1,83 @@
+package database
+
+import (
+	"L0_project/internal/model"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/lib/pq"
+)
+
+// Ошибки хранилища, по которым вызывающий код выбирает реакцию, не разбирая
+// ошибки драйвера. Ошибки Storage и MockStorage оборачивают одну из них.
+var (
+	// ErrNotFound — заказа нет. Ошибки Storage при этом оборачивают и sql.ErrNoRows.
+	ErrNotFound = errors.New("заказ не найден")
+	// ErrConflict — операция противоречит текущему состоянию заказа: версия
+	// изменилась, переход статуса недопустим, ключ идемпотентности уже занят,
+	// заказ с тем же order_uid уже создан, содержимое из Kafka пришло для заказа,
+	// изменённого через API.
+	ErrConflict = errors.New("конфликт с текущим состоянием заказа")
+	// ErrUnavailable — база данных недоступна. Операцию можно повторить позже.
+	ErrUnavailable = errors.New("база данных недоступна")
+)
+
+// classifiedError добавляет к ошибке одну из ErrNotFound, ErrConflict, ErrUnavailable,
+// не меняя текста ошибки.
+type classifiedError struct {
+	kind error
+	err  error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// classify оборачивает *err подходящей ошибкой хранилища. Используется как
+// defer classify(&err) сразу после defer metrics.ObserveDBQuery.
+func classify(err *error) {
+	if *err == nil || errors.Is(*err, ErrNotFound) || errors.Is(*err, ErrConflict) || errors.Is(*err, ErrUnavailable) {
+		return
+	}
+
+	var kind error
+	switch {
+	case errors.Is(*err, sql.ErrNoRows):
+		kind = ErrNotFound
+	case errors.Is(*err, ErrVersionMismatch), errors.Is(*err, ErrIdempotencyKeyReused), errors.Is(*err, ErrOrderExists),
+		errors.Is(*err, ErrOrderAmended), errors.Is(*err, model.ErrInvalidTransition), errors.Is(*err, model.ErrNotAmendable):
+		kind = ErrConflict
+	case isUnavailable(*err):
+		kind = ErrUnavailable
+	default:
+		return
+	}
+	*err = &classifiedError{kind: kind, err: *err}
+}
+
+// isUnavailable сообщает, что ошибка вызвана соединением с базой, а не запросом:
+// соединение разорвано или не устанавливается, Postgres перегружен или выключается.
+func isUnavailable(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case "08", "53":
+			return true
+		}
+		switch pqErr.Code {
+		case "57P01", "57P02", "57P03":
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -5,10 +5,27 @@ import (
 	"context"
 )
 
+// OutboxStorage — операции relay для таблицы outbox.
+type OutboxStorage interface {
+	RelayOutbox(ctx context.Context, limit int, publish PublishFunc) (int, error)
+}
+
 // OrderStorage описывает минимальный набор операций для работы с заказами
 type OrderStorage interface {
-	SaveOrder(ctx context.Context, order *model.Order) error
+	SaveOrder(ctx context.Context, order *model.Order) (SaveResult, error)
+	SaveOrders(ctx context.Context, orders []*model.Order) ([]SaveResult, error)
+	SubmitOrder(ctx context.Context, order *model.Order, key string) (SaveResult, error)
 	GetOrder(ctx context.Context, orderUID string) (*model.Order, error)
+	GetOrders(ctx context.Context, uids []string) ([]model.Order, error)
+	GetOrderByTrackNumber(ctx context.Context, trackNumber string) (*model.Order, error)
+	GetOrderByTransaction(ctx context.Context, transaction string) (*model.Order, error)
+	GetCustomerOrders(ctx context.Context, customerID string, cursor *Cursor, limit int) (OrderPage, error)
 	GetAllOrders(ctx context.Context) ([]model.Order, error)
 	GetRecentOrders(ctx context.Context, limit int) ([]model.Order, error)
+	ListOrders(ctx context.Context, filter OrderFilter, cursor *Cursor) (OrderPage, error)
+	UpdateStatus(ctx context.Context, orderUID string, to model.OrderStatus, source string) (bool, error)
+	GetStatusHistory(ctx context.Context, orderUID string) ([]model.StatusChange, error)
+	AmendOrder(ctx context.Context, orderUID string, version int, change Amendment) (*model.Order, error)
+	GetOrderAudit(ctx context.Context, orderUID string) ([]model.AuditEntry, error)
+	SearchOrders(ctx context.Context, query string, limit int) ([]SearchHit, error)
 }
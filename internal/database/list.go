@@ -0,0 +1,157 @@
+package database
+
+import (
+	"L0_project/internal/model"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultPageSize используется, если размер страницы не задан.
+	DefaultPageSize = 20
+	// MaxPageSize — максимальный размер страницы ListOrders.
+	MaxPageSize = 100
+)
+
+// ErrInvalidCursor возвращается при разборе повреждённого курсора.
+var ErrInvalidCursor = errors.New("некорректный курсор")
+
+// OrderFilter задаёт условия выборки для ListOrders. Пустые поля не участвуют в фильтрации.
+type OrderFilter struct {
+	CustomerID      string
+	TrackNumber     string
+	DeliveryService string
+	Locale          string
+	Currency        string
+	Provider        string
+	Bank            string
+	// CreatedFrom — нижняя граница date_created включительно.
+	CreatedFrom time.Time
+	// CreatedTo — верхняя граница date_created не включительно.
+	CreatedTo time.Time
+	// Limit — размер страницы, по умолчанию DefaultPageSize, не больше MaxPageSize.
+	Limit int
+}
+
+// PageSize возвращает размер страницы с учётом значений по умолчанию и ограничений.
+func (f OrderFilter) PageSize() int {
+	switch {
+	case f.Limit <= 0:
+		return DefaultPageSize
+	case f.Limit > MaxPageSize:
+		return MaxPageSize
+	default:
+		return f.Limit
+	}
+}
+
+// Cursor указывает на последний заказ предыдущей страницы. Заказы упорядочены
+// по (date_created, order_uid) по убыванию.
+type Cursor struct {
+	DateCreated time.Time
+	OrderUID    string
+}
+
+// Encode кодирует курсор в непрозрачную строку для передачи клиенту.
+func (c Cursor) Encode() string {
+	raw := c.DateCreated.UTC().Format(time.RFC3339Nano) + "|" + c.OrderUID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor разбирает строку, полученную из Cursor.Encode.
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	ts, uid, ok := strings.Cut(string(raw), "|")
+	if !ok || uid == "" {
+		return nil, ErrInvalidCursor
+	}
+	created, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{DateCreated: created, OrderUID: uid}, nil
+}
+
+// OrderPage — страница результатов ListOrders. Next равен nil на последней странице.
+type OrderPage struct {
+	Orders []model.Order
+	Next   *Cursor
+}
+
+// before сообщает, идёт ли заказ после курсора в порядке выдачи.
+func (c *Cursor) before(order *model.Order) bool {
+	if c == nil {
+		return true
+	}
+	if !order.DateCreated.Equal(c.DateCreated) {
+		return order.DateCreated.Before(c.DateCreated)
+	}
+	return order.OrderUID < c.OrderUID
+}
+
+// matches проверяет заказ на соответствие фильтру. Используется в MockStorage.
+func (f OrderFilter) matches(order *model.Order) bool {
+	eq := func(want, got string) bool { return want == "" || want == got }
+	return eq(f.CustomerID, order.CustomerID) &&
+		eq(f.TrackNumber, order.TrackNumber) &&
+		eq(f.DeliveryService, order.DeliveryService) &&
+		eq(f.Locale, order.Locale) &&
+		eq(f.Currency, order.Payment.Currency) &&
+		eq(f.Provider, order.Payment.Provider) &&
+		eq(f.Bank, order.Payment.Bank) &&
+		(f.CreatedFrom.IsZero() || !order.DateCreated.Before(f.CreatedFrom)) &&
+		(f.CreatedTo.IsZero() || order.DateCreated.Before(f.CreatedTo))
+}
+
+// where строит условие WHERE и аргументы запроса для фильтра и курсора.
+func (f OrderFilter) where(cursor *Cursor) (string, []any) {
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.CustomerID != "" {
+		add("o.customer_id = $%d", f.CustomerID)
+	}
+	if f.TrackNumber != "" {
+		add("o.track_number = $%d", f.TrackNumber)
+	}
+	if f.DeliveryService != "" {
+		add("o.delivery_service = $%d", f.DeliveryService)
+	}
+	if f.Locale != "" {
+		add("o.locale = $%d", f.Locale)
+	}
+	if f.Currency != "" {
+		add("p.currency = $%d", f.Currency)
+	}
+	if f.Provider != "" {
+		add("p.provider = $%d", f.Provider)
+	}
+	if f.Bank != "" {
+		add("p.bank = $%d", f.Bank)
+	}
+	if !f.CreatedFrom.IsZero() {
+		add("o.date_created >= $%d", f.CreatedFrom)
+	}
+	if !f.CreatedTo.IsZero() {
+		add("o.date_created < $%d", f.CreatedTo)
+	}
+	if cursor != nil {
+		args = append(args, cursor.DateCreated, cursor.OrderUID)
+		conds = append(conds, fmt.Sprintf("(o.date_created, o.order_uid) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
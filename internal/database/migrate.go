@@ -0,0 +1,389 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Таблица версий совместима с golang-migrate: одна строка с текущей версией
+// и флагом dirty. База, которую раньше мигрировали golang-migrate, продолжает
+// мигрироваться с той же версии.
+const createMigrationsTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT NOT NULL PRIMARY KEY,
+		dirty BOOLEAN NOT NULL
+	)`
+
+// migrationsLock — ключ сессионной advisory-блокировки: миграции с нескольких
+// реплик выполняются по очереди, а не одновременно.
+const migrationsLock = `hashtext('schema_migrations')`
+
+// ErrDirty возвращается, если предыдущая миграция завершилась с ошибкой посреди
+// выполнения (флаг dirty). Схему нужно исправить вручную и выполнить migrate force.
+var ErrDirty = errors.New("база данных в состоянии dirty")
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration — пара файлов {version}_{name}.up.sql и {version}_{name}.down.sql.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	// Down пустой, если файла отката нет.
+	Down string
+}
+
+// MigrationStep — применение или откат одной миграции.
+type MigrationStep struct {
+	Migration
+	// Direction — "up" или "down".
+	Direction string
+	// To — версия базы после шага, 0 — все миграции откатаны.
+	To int64
+}
+
+// SQL возвращает текст миграции для направления шага.
+func (s MigrationStep) SQL() string {
+	if s.Direction == "down" {
+		return s.Down
+	}
+	return s.Up
+}
+
+// MigrationStatus — текущая версия базы и список миграций.
+type MigrationStatus struct {
+	Version    int64
+	Dirty      bool
+	Migrations []Migration
+}
+
+// MigratorConfig — параметры Migrator.
+type MigratorConfig struct {
+	// DryRun — только вычислить шаги, не изменяя базу.
+	DryRun bool
+}
+
+// Migrator применяет встроенные миграции (см. пакет migrations) и ведёт таблицу schema_migrations.
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []Migration
+	dryRun     bool
+	log        *slog.Logger
+}
+
+// NewMigrator читает миграции из fsys. Ошибка в именах файлов или повторная
+// версия — ошибка создания, а не выполнения.
+func NewMigrator(s *Storage, fsys fs.FS, cfg MigratorConfig, log *slog.Logger) (*Migrator, error) {
+	migrations, err := readMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         s.db,
+		migrations: migrations,
+		dryRun:     cfg.DryRun,
+		log:        log.With("component", "migrator", "dry_run", cfg.DryRun),
+	}, nil
+}
+
+func readMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать каталог миграций: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := migrationFileRe.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("некорректная версия миграции %s", entry.Name())
+		}
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("не удалось прочитать миграцию %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("версия миграции %d повторяется: %s и %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("у миграции %d_%s нет файла up", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Latest возвращает версию последней встроенной миграции.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status возвращает текущую версию базы и список встроенных миграций.
+func (m *Migrator) Status(ctx context.Context) (MigrationStatus, error) {
+	version, dirty, err := currentVersion(ctx, m.db)
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+	return MigrationStatus{Version: version, Dirty: dirty, Migrations: m.migrations}, nil
+}
+
+// Up применяет steps следующих миграций, все оставшиеся — при steps <= 0.
+func (m *Migrator) Up(ctx context.Context, steps int) ([]MigrationStep, error) {
+	return m.run(ctx, func(current int64) (int64, error) {
+		i := m.index(current) + 1
+		if steps <= 0 || i+steps > len(m.migrations) {
+			return m.Latest(), nil
+		}
+		return m.migrations[i+steps-1].Version, nil
+	})
+}
+
+// Down откатывает steps последних применённых миграций, все — при steps <= 0.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]MigrationStep, error) {
+	return m.run(ctx, func(current int64) (int64, error) {
+		i := m.index(current) - steps
+		if steps <= 0 || i < 0 {
+			return 0, nil
+		}
+		return m.migrations[i].Version, nil
+	})
+}
+
+// Goto применяет или откатывает миграции до версии version; 0 — откатить все.
+func (m *Migrator) Goto(ctx context.Context, version int64) ([]MigrationStep, error) {
+	return m.run(ctx, func(int64) (int64, error) {
+		if version != 0 && m.index(version) < 0 {
+			return 0, fmt.Errorf("миграция %d не найдена", version)
+		}
+		return version, nil
+	})
+}
+
+// Force записывает версию без выполнения миграций и снимает флаг dirty.
+// Используется после ручного исправления схемы; 0 — удалить запись о версии.
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	if version != 0 && m.index(version) < 0 {
+		return fmt.Errorf("миграция %d не найдена", version)
+	}
+	if m.dryRun {
+		m.log.Info("версия будет установлена принудительно", "version", version)
+		return nil
+	}
+	return m.locked(ctx, func(conn *sqlx.Conn) error {
+		if _, err := conn.ExecContext(ctx, createMigrationsTable); err != nil {
+			return fmt.Errorf("не удалось создать таблицу schema_migrations: %w", err)
+		}
+		tx, err := conn.BeginTxx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("не удалось начать транзакцию: %w", err)
+		}
+		defer tx.Rollback()
+		if err := setVersion(ctx, tx, version); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+		}
+		m.log.Warn("версия установлена принудительно", "version", version)
+		return nil
+	})
+}
+
+// run вычисляет целевую версию от текущей и выполняет шаги под advisory-блокировкой.
+// Каждая миграция выполняется в отдельной транзакции вместе с записью новой версии,
+// поэтому ошибка откатывает только её, а база остаётся на предыдущей версии.
+func (m *Migrator) run(ctx context.Context, target func(current int64) (int64, error)) ([]MigrationStep, error) {
+	var steps []MigrationStep
+	err := m.locked(ctx, func(conn *sqlx.Conn) error {
+		if !m.dryRun {
+			if _, err := conn.ExecContext(ctx, createMigrationsTable); err != nil {
+				return fmt.Errorf("не удалось создать таблицу schema_migrations: %w", err)
+			}
+		}
+
+		current, dirty, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return fmt.Errorf("%w: версия %d", ErrDirty, current)
+		}
+		if current != 0 && m.index(current) < 0 {
+			return fmt.Errorf("версия базы %d не найдена среди миграций: база новее сервиса", current)
+		}
+
+		to, err := target(current)
+		if err != nil {
+			return err
+		}
+		steps, err = m.plan(current, to)
+		if err != nil {
+			return err
+		}
+		if len(steps) == 0 {
+			m.log.Info("миграции не требуются", "version", current)
+			return nil
+		}
+
+		for _, step := range steps {
+			log := m.log.With("version", step.Version, "name", step.Name, "direction", step.Direction)
+			if m.dryRun {
+				log.Info("миграция будет выполнена", "to", step.To)
+				continue
+			}
+			if err := applyStep(ctx, conn, step); err != nil {
+				return err
+			}
+			log.Info("миграция выполнена", "to", step.To)
+		}
+		return nil
+	})
+	return steps, err
+}
+
+// plan возвращает шаги от версии from до версии to.
+func (m *Migrator) plan(from, to int64) ([]MigrationStep, error) {
+	var steps []MigrationStep
+	if to >= from {
+		for _, mg := range m.migrations {
+			if mg.Version > from && mg.Version <= to {
+				steps = append(steps, MigrationStep{Migration: mg, Direction: "up", To: mg.Version})
+			}
+		}
+		return steps, nil
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mg := m.migrations[i]
+		if mg.Version > from || mg.Version <= to {
+			continue
+		}
+		if mg.Down == "" {
+			return nil, fmt.Errorf("у миграции %d_%s нет файла down", mg.Version, mg.Name)
+		}
+		var prev int64
+		if i > 0 {
+			prev = m.migrations[i-1].Version
+		}
+		steps = append(steps, MigrationStep{Migration: mg, Direction: "down", To: prev})
+	}
+	return steps, nil
+}
+
+// index возвращает позицию миграции с версией version, -1 — если её нет.
+func (m *Migrator) index(version int64) int {
+	for i, mg := range m.migrations {
+		if mg.Version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+// locked выполняет fn на отдельном соединении под сессионной advisory-блокировкой.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sqlx.Conn) error) error {
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("не удалось получить соединение: %w", err)
+	}
+	defer conn.Close()
+
+	m.log.Debug("ожидание блокировки миграций")
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock(`+migrationsLock+`)`); err != nil {
+		return fmt.Errorf("не удалось захватить блокировку миграций: %w", err)
+	}
+	defer func() {
+		// Блокировку нужно снять и после отмены контекста, иначе она останется
+		// на соединении, которое вернётся в пул.
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock(`+migrationsLock+`)`); err != nil {
+			m.log.Error("не удалось снять блокировку миграций", "error", err)
+		}
+	}()
+
+	return fn(conn)
+}
+
+func applyStep(ctx context.Context, conn *sqlx.Conn, step MigrationStep) error {
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, step.SQL()); err != nil {
+		return fmt.Errorf("не удалось выполнить миграцию %d_%s (%s): %w", step.Version, step.Name, step.Direction, err)
+	}
+	if err := setVersion(ctx, tx, step.To); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("не удалось зафиксировать миграцию %d_%s: %w", step.Version, step.Name, err)
+	}
+	return nil
+}
+
+// currentVersion читает версию базы; отсутствие таблицы или записи означает версию 0.
+func currentVersion(ctx context.Context, q sqlx.QueryerContext) (int64, bool, error) {
+	var version int64
+	var dirty bool
+	err := q.QueryRowxContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	var pqErr *pq.Error
+	switch {
+	case err == nil:
+		return version, dirty, nil
+	case errors.Is(err, sql.ErrNoRows):
+		return 0, false, nil
+	case errors.As(err, &pqErr) && pqErr.Code == "42P01": // undefined_table
+		return 0, false, nil
+	default:
+		return 0, false, fmt.Errorf("не удалось получить версию схемы: %w", err)
+	}
+}
+
+func setVersion(ctx context.Context, tx *sqlx.Tx, version int64) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return fmt.Errorf("не удалось обновить версию схемы: %w", err)
+	}
+	if version == 0 {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)`, version); err != nil {
+		return fmt.Errorf("не удалось обновить версию схемы: %w", err)
+	}
+	return nil
+}
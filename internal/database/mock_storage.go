@@ -4,20 +4,123 @@ import (
 	"L0_project/internal/model"
 	"context"
 	"fmt"
+	"maps"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
 )
 
 // MockStorage простой мок для тестов
 type MockStorage struct {
-	Orders map[string]model.Order
+	Orders  map[string]model.Order
+	History map[string][]model.StatusChange
+	// Keys — ключи идемпотентности SubmitOrder: ключ -> заказ первого запроса и результат.
+	Keys  map[string]MockSubmission
+	Audit map[string][]model.AuditEntry
+	// Ingested — хэш последнего принятого содержимого заказа, как orders.ingested_hash.
+	Ingested map[string]string
+}
+
+// MockSubmission — запомненный результат SubmitOrder с ключом идемпотентности.
+type MockSubmission struct {
+	Order  model.Order
+	Result SaveResult
 }
 
 func NewMockStorage() *MockStorage {
-	return &MockStorage{Orders: make(map[string]model.Order)}
+	return &MockStorage{
+		Orders:   make(map[string]model.Order),
+		History:  make(map[string][]model.StatusChange),
+		Keys:     make(map[string]MockSubmission),
+		Audit:    make(map[string][]model.AuditEntry),
+		Ingested: make(map[string]string),
+	}
+}
+
+func (m *MockStorage) SaveOrder(ctx context.Context, order *model.Order) (SaveResult, error) {
+	return m.save(order, model.StatusSourceKafka)
 }
 
-func (m *MockStorage) SaveOrder(ctx context.Context, order *model.Order) error {
-	m.Orders[order.OrderUID] = *order
-	return nil
+func (m *MockStorage) SubmitOrder(ctx context.Context, order *model.Order, key string) (SaveResult, error) {
+	if prev, ok := m.Keys[key]; ok && key != "" {
+		prev.Order.Status, prev.Order.Version = order.Status, order.Version
+		if !reflect.DeepEqual(prev.Order, *order) {
+			err := ErrIdempotencyKeyReused
+			classify(&err)
+			return 0, err
+		}
+		order.Status, order.Version = m.Orders[order.OrderUID].Status, m.Orders[order.OrderUID].Version
+		return prev.Result, nil
+	}
+
+	submitted := *order
+	result, err := m.save(order, model.StatusSourceAPI)
+	if err == nil && key != "" {
+		m.Keys[key] = MockSubmission{Order: submitted, Result: result}
+	}
+	return result, err
+}
+
+func (m *MockStorage) save(order *model.Order, source string) (SaveResult, error) {
+	existing, ok := m.Orders[order.OrderUID]
+	if ok {
+		order.Status, order.Version = existing.Status, existing.Version
+	} else {
+		order.Status, order.Version = model.StatusCreated, 1
+		m.History[order.OrderUID] = []model.StatusChange{{OrderUID: order.OrderUID, To: model.StatusCreated, Source: source, ChangedAt: time.Now()}}
+	}
+	hash, err := orderHash(order)
+	if err != nil {
+		return 0, err
+	}
+	current, err := orderHash(&existing)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case !ok:
+		m.Orders[order.OrderUID] = *order
+		m.Ingested[order.OrderUID] = hash
+		return SaveCreated, nil
+	case current == hash:
+		return SaveUnchanged, nil
+	case source == model.StatusSourceAPI:
+		err := fmt.Errorf("заказ %s: %w", order.OrderUID, ErrOrderExists)
+		classify(&err)
+		return 0, err
+	case m.Ingested[order.OrderUID] == hash:
+		return SaveUnchanged, nil
+	case !existing.SourceAt.IsZero() && !order.SourceAt.IsZero() && order.SourceAt.Before(existing.SourceAt):
+		return SaveStale, nil
+	case current != m.Ingested[order.OrderUID]:
+		err := fmt.Errorf("заказ %s: %w", order.OrderUID, ErrOrderAmended)
+		classify(&err)
+		return 0, err
+	default:
+		if order.SourceAt.IsZero() {
+			order.SourceAt = existing.SourceAt
+		}
+		order.Version++
+		m.Orders[order.OrderUID] = *order
+		m.Ingested[order.OrderUID] = hash
+		return SaveUpdated, nil
+	}
+}
+
+// SaveOrders, как и Storage.SaveOrders, сохраняет пачку атомарно: при ошибке
+// в любом заказе изменения всей пачки откатываются и возвращается эта ошибка.
+func (m *MockStorage) SaveOrders(ctx context.Context, orders []*model.Order) ([]SaveResult, error) {
+	savedOrders, savedHistory, savedIngested := maps.Clone(m.Orders), maps.Clone(m.History), maps.Clone(m.Ingested)
+	results := make([]SaveResult, len(orders))
+	for i, order := range orders {
+		var err error
+		if results[i], err = m.SaveOrder(ctx, order); err != nil {
+			m.Orders, m.History, m.Ingested = savedOrders, savedHistory, savedIngested
+			return nil, err
+		}
+	}
+	return results, nil
 }
 
 func (m *MockStorage) GetOrder(ctx context.Context, orderUID string) (*model.Order, error) {
@@ -27,6 +130,38 @@ func (m *MockStorage) GetOrder(ctx context.Context, orderUID string) (*model.Ord
 	return nil, ErrNotFound
 }
 
+func (m *MockStorage) GetOrderByTrackNumber(ctx context.Context, trackNumber string) (*model.Order, error) {
+	for _, o := range m.Orders {
+		if o.TrackNumber == trackNumber {
+			return &o, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *MockStorage) GetOrderByTransaction(ctx context.Context, transaction string) (*model.Order, error) {
+	for _, o := range m.Orders {
+		if o.Payment.Transaction == transaction {
+			return &o, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *MockStorage) GetCustomerOrders(ctx context.Context, customerID string, cursor *Cursor, limit int) (OrderPage, error) {
+	return m.ListOrders(ctx, OrderFilter{CustomerID: customerID, Limit: limit}, cursor)
+}
+
+func (m *MockStorage) GetOrders(ctx context.Context, uids []string) ([]model.Order, error) {
+	var res []model.Order
+	for _, uid := range uids {
+		if o, ok := m.Orders[uid]; ok {
+			res = append(res, o)
+		}
+	}
+	return res, nil
+}
+
 func (m *MockStorage) GetAllOrders(ctx context.Context) ([]model.Order, error) {
 	var res []model.Order
 	for _, o := range m.Orders {
@@ -39,5 +174,132 @@ func (m *MockStorage) GetRecentOrders(ctx context.Context, limit int) ([]model.O
 	return m.GetAllOrders(ctx)
 }
 
-// ErrNotFound используется в моках
-var ErrNotFound = fmt.Errorf("not found")
+func (m *MockStorage) ListOrders(ctx context.Context, filter OrderFilter, cursor *Cursor) (OrderPage, error) {
+	var matched []model.Order
+	for _, o := range m.Orders {
+		if filter.matches(&o) && cursor.before(&o) {
+			matched = append(matched, o)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].DateCreated.Equal(matched[j].DateCreated) {
+			return matched[i].DateCreated.After(matched[j].DateCreated)
+		}
+		return matched[i].OrderUID > matched[j].OrderUID
+	})
+
+	page := OrderPage{Orders: matched}
+	if size := filter.PageSize(); len(matched) > size {
+		page.Orders = matched[:size]
+		last := page.Orders[size-1]
+		page.Next = &Cursor{DateCreated: last.DateCreated, OrderUID: last.OrderUID}
+	}
+	return page, nil
+}
+
+func (m *MockStorage) UpdateStatus(ctx context.Context, orderUID string, to model.OrderStatus, source string) (bool, error) {
+	o, ok := m.Orders[orderUID]
+	if !ok {
+		return false, ErrNotFound
+	}
+	if o.Status == to {
+		return false, nil
+	}
+	if err := o.Status.Transition(to); err != nil {
+		classify(&err)
+		return false, err
+	}
+	m.History[orderUID] = append(m.History[orderUID], model.StatusChange{OrderUID: orderUID, From: o.Status, To: to, Source: source, ChangedAt: time.Now()})
+	o.Status = to
+	o.Version++
+	m.Orders[orderUID] = o
+	return true, nil
+}
+
+func (m *MockStorage) AmendOrder(ctx context.Context, orderUID string, version int, change Amendment) (*model.Order, error) {
+	o, ok := m.Orders[orderUID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if o.Version != version {
+		err := fmt.Errorf("заказ %s: %w", orderUID, ErrVersionMismatch)
+		classify(&err)
+		return nil, err
+	}
+	after, changed, err := amend(&o, change)
+	if err != nil {
+		classify(&err)
+		return nil, err
+	}
+	if !changed {
+		return &o, nil
+	}
+	after.Version++
+
+	entry, err := auditEntry(&o, after, change)
+	if err != nil {
+		return nil, err
+	}
+	entry.ChangedAt = time.Now()
+	m.Audit[orderUID] = append(m.Audit[orderUID], entry)
+	if after.Status != o.Status {
+		m.History[orderUID] = append(m.History[orderUID], model.StatusChange{OrderUID: orderUID, From: o.Status, To: after.Status, Source: model.StatusSourceAPI, ChangedAt: time.Now()})
+	}
+	m.Orders[orderUID] = *after
+	return after, nil
+}
+
+func (m *MockStorage) GetOrderAudit(ctx context.Context, orderUID string) ([]model.AuditEntry, error) {
+	if _, ok := m.Orders[orderUID]; !ok {
+		return nil, ErrNotFound
+	}
+	return m.Audit[orderUID], nil
+}
+
+func (m *MockStorage) GetStatusHistory(ctx context.Context, orderUID string) ([]model.StatusChange, error) {
+	history, ok := m.History[orderUID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return history, nil
+}
+
+// SearchOrders ищет подстроку без учёта регистра в тех же полях, что и Storage,
+// без поиска с опечатками. Ранг — число совпавших источников.
+func (m *MockStorage) SearchOrders(ctx context.Context, query string, limit int) ([]SearchHit, error) {
+	needle := strings.ToLower(query)
+	var hits []SearchHit
+	for _, o := range m.Orders {
+		d := o.Delivery
+		docs := [][2]string{
+			{SearchSourceOrder, strings.Join([]string{o.OrderUID, o.TrackNumber, o.CustomerID}, " ")},
+			{SearchSourceDelivery, strings.Join([]string{d.Name, d.Email, d.Phone, d.Zip, d.City, d.Address, d.Region}, " ")},
+		}
+		for _, item := range o.Items {
+			docs = append(docs, [2]string{SearchSourceItem, item.Name + " " + item.Brand})
+		}
+
+		hit := SearchHit{Order: o}
+		for _, doc := range docs {
+			if i := strings.Index(strings.ToLower(doc[1]), needle); i >= 0 && needle != "" {
+				text := doc[1]
+				fragment := text[:i] + HighlightStart + text[i:i+len(needle)] + HighlightStop + text[i+len(needle):]
+				hit.Highlights = append(hit.Highlights, SearchHighlight{Source: doc[0], Fragment: fragment})
+			}
+		}
+		if hit.Highlights != nil {
+			hit.Rank = float64(len(hit.Highlights))
+			hits = append(hits, hit)
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Rank != hits[j].Rank {
+			return hits[i].Rank > hits[j].Rank
+		}
+		return hits[i].Order.OrderUID < hits[j].Order.OrderUID
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// OrderChangesChannel — канал LISTEN/NOTIFY, в который Storage публикует order_uid
+// изменённых заказов. Уведомление отправляется в транзакции изменения и доставляется
+// подписчикам только после её фиксации.
+const OrderChangesChannel = "order_changes"
+
+// notifyOrderChanged ставит уведомление об изменении заказа в очередь транзакции tx.
+func notifyOrderChanged(ctx context.Context, tx *sqlx.Tx, orderUID string) error {
+	if _, err := tx.ExecContext(ctx, `SELECT pg_notify($1, $2)`, OrderChangesChannel, orderUID); err != nil {
+		return fmt.Errorf("не удалось отправить уведомление об изменении заказа %s: %w", orderUID, err)
+	}
+	return nil
+}
+
+// ChangeListener получает уведомления об изменении заказов из OrderChangesChannel.
+// Соединение для LISTEN отдельное от пула Storage и переустанавливается автоматически.
+type ChangeListener struct {
+	listener *pq.Listener
+	log      *slog.Logger
+}
+
+// NewChangeListener подключается к базе и подписывается на OrderChangesChannel.
+func NewChangeListener(databaseURL string, log *slog.Logger) (*ChangeListener, error) {
+	log = log.With("component", "change_listener")
+	l := pq.NewListener(databaseURL, time.Second, 30*time.Second, func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventDisconnected:
+			log.Warn("соединение для уведомлений потеряно", "error", err)
+		case pq.ListenerEventConnectionAttemptFailed:
+			log.Warn("не удалось восстановить соединение для уведомлений", "error", err)
+		case pq.ListenerEventReconnected:
+			log.Info("соединение для уведомлений восстановлено")
+		}
+	})
+	if err := l.Listen(OrderChangesChannel); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("не удалось подписаться на канал %s: %w", OrderChangesChannel, err)
+	}
+	return &ChangeListener{listener: l, log: log}, nil
+}
+
+// Run вызывает onChange для каждого изменённого заказа до отмены контекста.
+// Пока соединение было потеряно, уведомления могли пропасть, поэтому после
+// переподключения вызывается onReset.
+func (l *ChangeListener) Run(ctx context.Context, onChange func(orderUID string), onReset func()) {
+	defer l.listener.Close()
+
+	ping := time.NewTicker(time.Minute)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-l.listener.Notify:
+			if n == nil {
+				l.log.Info("уведомления могли быть потеряны, кэш сброшен")
+				onReset()
+				continue
+			}
+			l.log.Debug("получено уведомление об изменении заказа", "order_uid", n.Extra)
+			onChange(n.Extra)
+		case <-ping.C:
+			// Ping обнаруживает обрыв соединения, когда уведомлений давно не было.
+			if err := l.listener.Ping(); err != nil {
+				l.log.Warn("соединение для уведомлений не отвечает", "error", err)
+			}
+		}
+	}
+}
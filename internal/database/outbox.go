@@ -0,0 +1,108 @@
+package database
+
+import (
+	"L0_project/internal/metrics"
+	"L0_project/internal/model"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// OutboxEvent — событие из таблицы outbox, ожидающее публикации.
+type OutboxEvent struct {
+	ID          int64     `db:"id"`
+	AggregateID string    `db:"aggregate_id"`
+	EventType   string    `db:"event_type"`
+	Payload     []byte    `db:"payload"`
+	Attempts    int       `db:"attempts"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// PublishFunc публикует пакет событий. Ошибка означает, что ни одно событие
+// пакета не считается опубликованным.
+type PublishFunc func(ctx context.Context, events []OutboxEvent) error
+
+// orderAcceptedEvent сериализует событие OrderAccepted для записи в outbox.
+func orderAcceptedEvent(order *model.Order) ([]byte, error) {
+	b, err := json.Marshal(model.NewOrderAccepted(order, time.Now().UTC()))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сериализовать событие %s для заказа %s: %w", model.EventOrderAccepted, order.OrderUID, err)
+	}
+	return b, nil
+}
+
+// insertOrderAccepted записывает событие OrderAccepted в outbox в транзакции сохранения заказа.
+func insertOrderAccepted(ctx context.Context, tx *sqlx.Tx, order *model.Order) error {
+	payload, err := orderAcceptedEvent(order)
+	if err != nil {
+		return err
+	}
+	// Строка, а не []byte: pq передаёт []byte как bytea, а столбец payload — jsonb.
+	_, err = tx.ExecContext(ctx, `INSERT INTO outbox (aggregate_id, event_type, payload) VALUES ($1, $2, $3)`,
+		order.OrderUID, model.EventOrderAccepted, string(payload))
+	if err != nil {
+		return fmt.Errorf("не удалось записать событие в outbox для заказа %s: %w", order.OrderUID, err)
+	}
+	return nil
+}
+
+// RelayOutbox выбирает до limit неопубликованных событий в порядке записи, передаёт
+// их publish и отмечает опубликованными. Строки блокируются с SKIP LOCKED, поэтому
+// несколько реплик не публикуют одно событие одновременно. Если publish вернул
+// ошибку, у событий увеличивается счётчик попыток и сохраняется текст ошибки, а
+// ошибка возвращается вызывающему. Возвращает число опубликованных событий.
+//
+// Публикация и отметка не атомарны: если процесс упадёт между ними, события будут
+// опубликованы повторно. Получатели различают повторы по идентификатору события.
+func (s *Storage) RelayOutbox(ctx context.Context, limit int, publish PublishFunc) (_ int, err error) {
+	defer metrics.ObserveDBQuery("RelayOutbox", time.Now(), &err)
+	defer classify(&err)
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback()
+
+	var events []OutboxEvent
+	query := `SELECT id, aggregate_id, event_type, payload, attempts, created_at
+              FROM outbox
+              WHERE published_at IS NULL
+              ORDER BY id
+              LIMIT $1
+              FOR UPDATE SKIP LOCKED`
+	if err := tx.SelectContext(ctx, &events, query, limit); err != nil {
+		return 0, fmt.Errorf("не удалось выбрать события из outbox: %w", err)
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]int64, len(events))
+	for i, e := range events {
+		ids[i] = e.ID
+	}
+
+	if pubErr := publish(ctx, events); pubErr != nil {
+		_, err := tx.ExecContext(ctx, `UPDATE outbox SET attempts = attempts + 1, last_error = $2 WHERE id = ANY($1)`, pq.Array(ids), pubErr.Error())
+		if err != nil {
+			return 0, fmt.Errorf("не удалось записать ошибку публикации в outbox: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+		}
+		return 0, pubErr
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE outbox SET published_at = now(), attempts = attempts + 1, last_error = NULL WHERE id = ANY($1)`, pq.Array(ids)); err != nil {
+		return 0, fmt.Errorf("не удалось отметить события outbox опубликованными: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+	}
+	return len(events), nil
+}
@@ -1,21 +1,25 @@
 package database
 
 import (
+	"L0_project/internal/metrics"
 	"L0_project/internal/model"
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
+	"time"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type Storage struct {
-	db *sqlx.DB
+	db  *sqlx.DB
+	log *slog.Logger
 }
 
-func New(databaseURL string) (*Storage, error) {
+func New(databaseURL string, log *slog.Logger) (*Storage, error) {
 	db, err := sqlx.Connect("postgres", databaseURL)
 	if err != nil {
 		return nil, fmt.Errorf("не удалось подключиться к базе данных: %w", err)
@@ -25,34 +29,115 @@ func New(databaseURL string) (*Storage, error) {
 		return nil, fmt.Errorf("не удалось проверить соединение с базой данных: %w", err)
 	}
 
-	return &Storage{db: db}, nil
+	return &Storage{db: db, log: log.With("component", "storage")}, nil
 }
 
-func (s *Storage) ApplyMigrations(path string) error {
-	c, err := os.ReadFile(path)
-	if err != nil {
-		return fmt.Errorf("не удалось прочитать файл схемы: %w", err)
-	}
-	schema := string(c)
-	_, err = s.db.ExecContext(context.Background(), schema)
+// SaveOrder идемпотентно сохраняет заказ. Повторная доставка того же заказа ничего
+// не меняет, а заказ с изменённым содержимым обновляется с увеличением версии.
+// Заказ из сообщения старше того, из которого сохранено текущее содержимое
+// (order.SourceAt), не сохраняется и возвращает SaveStale: запоздавшая повторная
+// доставка не откатывает более новое содержимое.
+// Статус заказа при этом не меняется: в order.Status записывается сохранённый статус,
+// а в order.Version — версия после сохранения.
+func (s *Storage) SaveOrder(ctx context.Context, order *model.Order) (_ SaveResult, err error) {
+	defer metrics.ObserveDBQuery("SaveOrder", time.Now(), &err)
+	defer classify(&err)
+
+	hash, err := orderHash(order)
 	if err != nil {
-		return fmt.Errorf("не удалось применить migrations: %w", err)
+		return 0, err
 	}
-	log.Println("Migrations успешно применены")
-	return nil
-}
 
-func (s *Storage) SaveOrder(ctx context.Context, order *model.Order) error {
 	tx, err := s.db.BeginTxx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("не удалось начать транзакцию: %w", err)
+		return 0, fmt.Errorf("не удалось начать транзакцию: %w", err)
 	}
 	defer tx.Rollback()
 
+	// Блокировка по order_uid сериализует конкурентные сохранения одного заказа,
+	// в том числе когда строки в orders ещё нет.
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, order.OrderUID); err != nil {
+		return 0, fmt.Errorf("не удалось заблокировать заказ %s: %w", order.OrderUID, err)
+	}
+
+	result, err := saveOrderTx(ctx, tx, order, hash, model.StatusSourceKafka)
+	if err != nil || result == SaveUnchanged || result == SaveStale {
+		return result, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+	}
+	return result, nil
+}
+
+// saveOrderTx сохраняет заказ в транзакции tx, в которой заказ уже заблокирован.
+// source записывается в историю статусов как источник создания заказа. Заказ из API
+// (model.StatusSourceAPI) не обновляет уже сохранённый заказ с другим содержимым.
+// Заказ из Kafka для заказа, содержимое которого изменено через API (AmendOrder),
+// ничего не меняет, если повторяет последнее принятое содержимое (ingested_hash),
+// а с другим содержимым возвращает ErrOrderAmended.
+func saveOrderTx(ctx context.Context, tx *sqlx.Tx, order *model.Order, hash, source string) (SaveResult, error) {
+	var current struct {
+		PayloadHash  string            `db:"payload_hash"`
+		IngestedHash string            `db:"ingested_hash"`
+		DeliveryID   int               `db:"delivery_id"`
+		PaymentID    int               `db:"payment_id"`
+		Status       model.OrderStatus `db:"status"`
+		Version      int               `db:"version"`
+		SourceAt     sql.NullTime      `db:"source_at"`
+	}
+	query := `SELECT payload_hash, ingested_hash, delivery_id, payment_id, status, version, source_at
+              FROM orders
+              WHERE order_uid = $1
+              FOR UPDATE`
+	err := tx.GetContext(ctx, &current, query, order.OrderUID)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		if err := insertOrder(ctx, tx, order, hash); err != nil {
+			return 0, err
+		}
+		if err := insertStatusChange(ctx, tx, model.StatusChange{OrderUID: order.OrderUID, To: model.StatusCreated, Source: source}); err != nil {
+			return 0, err
+		}
+		if err := insertOrderAccepted(ctx, tx, order); err != nil {
+			return 0, err
+		}
+		order.Status, order.Version = model.StatusCreated, 1
+		return SaveCreated, nil
+	case err != nil:
+		return 0, fmt.Errorf("не удалось проверить наличие заказа %s: %w", order.OrderUID, err)
+	case current.PayloadHash == hash:
+		order.Status, order.Version = current.Status, current.Version
+		return SaveUnchanged, nil
+	case source == model.StatusSourceAPI:
+		return 0, fmt.Errorf("заказ %s: %w", order.OrderUID, ErrOrderExists)
+	case current.IngestedHash == hash:
+		order.Status, order.Version = current.Status, current.Version
+		return SaveUnchanged, nil
+	case !order.SourceAt.IsZero() && current.SourceAt.Valid && order.SourceAt.Before(current.SourceAt.Time):
+		order.Status, order.Version = current.Status, current.Version
+		return SaveStale, nil
+	case current.PayloadHash != current.IngestedHash:
+		return 0, fmt.Errorf("заказ %s: %w", order.OrderUID, ErrOrderAmended)
+	default:
+		order.Status, order.Version = current.Status, current.Version+1
+		if err := updateOrder(ctx, tx, order, hash, current.DeliveryID, current.PaymentID, true); err != nil {
+			return 0, err
+		}
+		if err := notifyOrderChanged(ctx, tx, order.OrderUID); err != nil {
+			return 0, err
+		}
+		return SaveUpdated, nil
+	}
+}
+
+func insertOrder(ctx context.Context, tx *sqlx.Tx, order *model.Order, hash string) error {
 	var deliveryID int
 	deliveryQuery := `INSERT INTO deliveries (name, phone, zip, city, address, region, email)
                      VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
-	err = tx.QueryRowxContext(ctx, deliveryQuery, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip, order.Delivery.City, order.Delivery.Address, order.Delivery.Region, order.Delivery.Email).Scan(&deliveryID)
+	err := tx.QueryRowxContext(ctx, deliveryQuery, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip, order.Delivery.City, order.Delivery.Address, order.Delivery.Region, order.Delivery.Email).Scan(&deliveryID)
 	if err != nil {
 		return fmt.Errorf("не удалось вставить данные доставки: %w", err)
 	}
@@ -65,32 +150,72 @@ func (s *Storage) SaveOrder(ctx context.Context, order *model.Order) error {
 		return fmt.Errorf("не удалось вставить данные оплаты: %w", err)
 	}
 
-	orderQuery := `INSERT INTO orders (order_uid, track_number, entry, delivery_id, payment_id, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
-                 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
-	_, err = tx.ExecContext(ctx, orderQuery, order.OrderUID, order.TrackNumber, order.Entry, deliveryID, paymentID, order.Locale, order.InternalSignature, order.CustomerID, order.DeliveryService, order.Shardkey, order.SmID, order.DateCreated, order.OofShard)
+	orderQuery := `INSERT INTO orders (order_uid, track_number, entry, delivery_id, payment_id, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, payload_hash, status, source_at, ingested_hash)
+                 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`
+	_, err = tx.ExecContext(ctx, orderQuery, order.OrderUID, order.TrackNumber, order.Entry, deliveryID, paymentID, order.Locale, order.InternalSignature, order.CustomerID, order.DeliveryService, order.Shardkey, order.SmID, order.DateCreated, order.OofShard, hash, model.StatusCreated, sourceAt(order), hash)
 	if err != nil {
 		return fmt.Errorf("не удалось вставить данные заказа: %w", err)
 	}
 
+	return insertItems(ctx, tx, order)
+}
+
+// updateOrder заменяет содержимое заказа. ingested — содержимое принято из Kafka,
+// а не изменено через API: тогда оно же становится последним принятым (ingested_hash).
+func updateOrder(ctx context.Context, tx *sqlx.Tx, order *model.Order, hash string, deliveryID, paymentID int, ingested bool) error {
+	deliveryQuery := `UPDATE deliveries SET name = $1, phone = $2, zip = $3, city = $4, address = $5, region = $6, email = $7
+                     WHERE id = $8`
+	_, err := tx.ExecContext(ctx, deliveryQuery, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip, order.Delivery.City, order.Delivery.Address, order.Delivery.Region, order.Delivery.Email, deliveryID)
+	if err != nil {
+		return fmt.Errorf("не удалось обновить данные доставки: %w", err)
+	}
+
+	paymentQuery := `UPDATE payments SET transaction = $1, request_id = $2, currency = $3, provider = $4, amount = $5, payment_dt = $6, bank = $7, delivery_cost = $8, goods_total = $9, custom_fee = $10
+                   WHERE id = $11`
+	_, err = tx.ExecContext(ctx, paymentQuery, order.Payment.Transaction, order.Payment.RequestID, order.Payment.Currency, order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentDt, order.Payment.Bank, order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee, paymentID)
+	if err != nil {
+		return fmt.Errorf("не удалось обновить данные оплаты: %w", err)
+	}
+
+	// Изменение через API (order.SourceAt нулевое) сохраняет время последнего сообщения.
+	orderQuery := `UPDATE orders SET track_number = $2, entry = $3, locale = $4, internal_signature = $5, customer_id = $6, delivery_service = $7, shardkey = $8, sm_id = $9, date_created = $10, oof_shard = $11,
+                     payload_hash = $12, source_at = COALESCE($13, source_at), ingested_hash = CASE WHEN $14 THEN $12 ELSE ingested_hash END,
+                     version = version + 1, updated_at = now()
+                 WHERE order_uid = $1`
+	_, err = tx.ExecContext(ctx, orderQuery, order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature, order.CustomerID, order.DeliveryService, order.Shardkey, order.SmID, order.DateCreated, order.OofShard, hash, sourceAt(order), ingested)
+	if err != nil {
+		return fmt.Errorf("не удалось обновить данные заказа: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM items WHERE order_uid = $1`, order.OrderUID); err != nil {
+		return fmt.Errorf("не удалось удалить товары заказа %s: %w", order.OrderUID, err)
+	}
+	return insertItems(ctx, tx, order)
+}
+
+// sourceAt возвращает order.SourceAt для колонки source_at: NULL, если время неизвестно.
+func sourceAt(order *model.Order) sql.NullTime {
+	return sql.NullTime{Time: order.SourceAt, Valid: !order.SourceAt.IsZero()}
+}
+
+func insertItems(ctx context.Context, tx *sqlx.Tx, order *model.Order) error {
 	for _, item := range order.Items {
 		itemQuery := `INSERT INTO items (order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status)
                     VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
-		_, err = tx.ExecContext(ctx, itemQuery, order.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.Rid, item.Name, item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status)
+		_, err := tx.ExecContext(ctx, itemQuery, order.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.Rid, item.Name, item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status)
 		if err != nil {
 			return fmt.Errorf("не удалось вставить товар для заказа %s: %w", order.OrderUID, err)
 		}
 	}
-
-	return tx.Commit()
+	return nil
 }
 
-func (s *Storage) GetOrder(ctx context.Context, orderUID string) (*model.Order, error) {
-	var order model.Order
-
-	query := `
+// orderSelect выбирает заказы вместе с доставкой и оплатой одним запросом.
+// Условия, сортировка и лимит дописываются вызывающим кодом.
+const orderSelect = `
         SELECT
             o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature, o.customer_id, o.delivery_service,
-            o.shardkey, o.sm_id, o.date_created, o.oof_shard,
+            o.shardkey, o.sm_id, o.date_created, o.oof_shard, o.status, o.version,
             d.name "delivery.name", d.phone "delivery.phone", d.zip "delivery.zip", d.city "delivery.city",
             d.address "delivery.address", d.region "delivery.region", d.email "delivery.email",
             p.transaction "payment.transaction", p.request_id "payment.request_id", p.currency "payment.currency",
@@ -98,62 +223,187 @@ func (s *Storage) GetOrder(ctx context.Context, orderUID string) (*model.Order,
             p.delivery_cost "payment.delivery_cost", p.goods_total "payment.goods_total", p.custom_fee "payment.custom_fee"
         FROM orders o
         JOIN deliveries d ON o.delivery_id = d.id
-        JOIN payments p ON o.payment_id = p.id
-        WHERE o.order_uid = $1`
+        JOIN payments p ON o.payment_id = p.id`
 
-	err := s.db.GetContext(ctx, &order, query, orderUID)
+const itemsSelect = `
+        SELECT id, order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status
+        FROM items
+        WHERE order_uid = ANY($1)
+        ORDER BY order_uid, id`
+
+func (s *Storage) GetOrder(ctx context.Context, orderUID string) (_ *model.Order, err error) {
+	defer metrics.ObserveDBQuery("GetOrder", time.Now(), &err)
+	defer classify(&err)
+
+	orders, err := s.selectOrders(ctx, orderSelect+` WHERE o.order_uid = $1`, orderUID)
 	if err != nil {
 		return nil, fmt.Errorf("не удалось получить заказ %s: %w", orderUID, err)
 	}
+	if len(orders) == 0 {
+		return nil, fmt.Errorf("не удалось получить заказ %s: %w", orderUID, sql.ErrNoRows)
+	}
+	return &orders[0], nil
+}
+
+// GetOrderByTrackNumber возвращает заказ по трек-номеру.
+func (s *Storage) GetOrderByTrackNumber(ctx context.Context, trackNumber string) (_ *model.Order, err error) {
+	defer metrics.ObserveDBQuery("GetOrderByTrackNumber", time.Now(), &err)
+	defer classify(&err)
+
+	orders, err := s.selectOrders(ctx, orderSelect+` WHERE o.track_number = $1`, trackNumber)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить заказ по трек-номеру %s: %w", trackNumber, err)
+	}
+	if len(orders) == 0 {
+		return nil, fmt.Errorf("не удалось получить заказ по трек-номеру %s: %w", trackNumber, sql.ErrNoRows)
+	}
+	return &orders[0], nil
+}
+
+// GetOrderByTransaction возвращает заказ по идентификатору платёжной транзакции.
+func (s *Storage) GetOrderByTransaction(ctx context.Context, transaction string) (_ *model.Order, err error) {
+	defer metrics.ObserveDBQuery("GetOrderByTransaction", time.Now(), &err)
+	defer classify(&err)
 
-	itemsQuery := `SELECT * FROM items WHERE order_uid = $1`
-	err = s.db.SelectContext(ctx, &order.Items, itemsQuery, orderUID)
+	orders, err := s.selectOrders(ctx, orderSelect+` WHERE p.transaction = $1`, transaction)
 	if err != nil {
-		return nil, fmt.Errorf("не удалось получить товары для заказа %s: %w", orderUID, err)
+		return nil, fmt.Errorf("не удалось получить заказ по транзакции %s: %w", transaction, err)
 	}
+	if len(orders) == 0 {
+		return nil, fmt.Errorf("не удалось получить заказ по транзакции %s: %w", transaction, sql.ErrNoRows)
+	}
+	return &orders[0], nil
+}
 
-	return &order, nil
+// GetCustomerOrders возвращает страницу заказов покупателя, начиная после cursor.
+func (s *Storage) GetCustomerOrders(ctx context.Context, customerID string, cursor *Cursor, limit int) (OrderPage, error) {
+	return s.ListOrders(ctx, OrderFilter{CustomerID: customerID, Limit: limit}, cursor)
 }
 
-func (s *Storage) GetAllOrders(ctx context.Context) ([]model.Order, error) {
-	var orderUIDs []string
-	err := s.db.SelectContext(ctx, &orderUIDs, "SELECT order_uid FROM orders ORDER BY date_created DESC")
+// GetOrders загружает заказы по списку идентификаторов двумя запросами: заказы с
+// доставкой и оплатой, затем товары всех заказов. Порядок результата совпадает с
+// порядком uids, отсутствующие заказы пропускаются.
+func (s *Storage) GetOrders(ctx context.Context, uids []string) (_ []model.Order, err error) {
+	defer metrics.ObserveDBQuery("GetOrders", time.Now(), &err)
+	defer classify(&err)
+
+	if len(uids) == 0 {
+		return nil, nil
+	}
+
+	orders, err := s.selectOrders(ctx, orderSelect+` WHERE o.order_uid = ANY($1)`, pq.Array(uids))
 	if err != nil {
-		return nil, fmt.Errorf("не удалось получить все идентификаторы заказов: %w", err)
+		return nil, fmt.Errorf("не удалось получить заказы: %w", err)
 	}
 
-	var orders []model.Order
-	for _, uid := range orderUIDs {
-		order, err := s.GetOrder(ctx, uid)
-		if err != nil {
-			log.Printf("Ошибка загрузки заказа %s для прогрева кеша: %v", uid, err)
-			continue
+	byUID := make(map[string]model.Order, len(orders))
+	for _, order := range orders {
+		byUID[order.OrderUID] = order
+	}
+	result := make([]model.Order, 0, len(orders))
+	for _, uid := range uids {
+		if order, ok := byUID[uid]; ok {
+			result = append(result, order)
+			delete(byUID, uid)
 		}
-		orders = append(orders, *order)
 	}
+	return result, nil
+}
 
+func (s *Storage) GetAllOrders(ctx context.Context) (_ []model.Order, err error) {
+	defer metrics.ObserveDBQuery("GetAllOrders", time.Now(), &err)
+	defer classify(&err)
+
+	orders, err := s.selectOrders(ctx, orderSelect+` ORDER BY o.date_created DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить все заказы: %w", err)
+	}
+	return orders, nil
+}
+
+func (s *Storage) GetRecentOrders(ctx context.Context, limit int) (_ []model.Order, err error) {
+	defer metrics.ObserveDBQuery("GetRecentOrders", time.Now(), &err)
+	defer classify(&err)
+
+	orders, err := s.selectOrders(ctx, orderSelect+` ORDER BY o.date_created DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить последние заказы: %w", err)
+	}
 	return orders, nil
 }
 
-func (s *Storage) GetRecentOrders(ctx context.Context, limit int) ([]model.Order, error) {
-	var orderUIDs []string
-	query := `SELECT order_uid FROM orders ORDER BY date_created DESC LIMIT $1`
-	err := s.db.SelectContext(ctx, &orderUIDs, query, limit)
+// ListOrders возвращает страницу заказов, отфильтрованных по filter, начиная после cursor.
+// Пагинация курсорная по (date_created, order_uid), поэтому новые заказы не сдвигают страницы.
+func (s *Storage) ListOrders(ctx context.Context, filter OrderFilter, cursor *Cursor) (_ OrderPage, err error) {
+	defer metrics.ObserveDBQuery("ListOrders", time.Now(), &err)
+	defer classify(&err)
+
+	where, args := filter.where(cursor)
+	size := filter.PageSize()
+	args = append(args, size+1)
+	query := orderSelect + where + fmt.Sprintf(` ORDER BY o.date_created DESC, o.order_uid DESC LIMIT $%d`, len(args))
+
+	orders, err := s.selectOrders(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("не удалось получить последние идентификаторы заказов: %w", err)
+		return OrderPage{}, fmt.Errorf("не удалось получить список заказов: %w", err)
 	}
 
+	page := OrderPage{Orders: orders}
+	if len(orders) > size {
+		page.Orders = orders[:size]
+		last := page.Orders[size-1]
+		page.Next = &Cursor{DateCreated: last.DateCreated, OrderUID: last.OrderUID}
+	}
+	return page, nil
+}
+
+// selectOrders выполняет запрос на основе orderSelect и дозагружает товары.
+func (s *Storage) selectOrders(ctx context.Context, query string, args ...any) ([]model.Order, error) {
+	return queryOrders(ctx, s.db, query, args...)
+}
+
+// queryOrders — selectOrders для произвольного q, в том числе транзакции.
+func queryOrders(ctx context.Context, q sqlx.QueryerContext, query string, args ...any) ([]model.Order, error) {
 	var orders []model.Order
-	for _, uid := range orderUIDs {
-		order, err := s.GetOrder(ctx, uid)
-		if err != nil {
-			log.Printf("Ошибка загрузки заказа %s для списка последних заказов: %v", uid, err)
-			continue
+	if err := sqlx.SelectContext(ctx, q, &orders, query, args...); err != nil {
+		return nil, err
+	}
+	if err := attachItems(ctx, q, orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// attachItems загружает товары для всех переданных заказов одним запросом
+// и раскладывает их по заказам.
+func attachItems(ctx context.Context, q sqlx.QueryerContext, orders []model.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	uids := make([]string, len(orders))
+	index := make(map[string]int, len(orders))
+	for i, order := range orders {
+		uids[i] = order.OrderUID
+		index[order.OrderUID] = i
+	}
+
+	var items []model.Item
+	if err := sqlx.SelectContext(ctx, q, &items, itemsSelect, pq.Array(uids)); err != nil {
+		return fmt.Errorf("не удалось получить товары заказов: %w", err)
+	}
+
+	for _, item := range items {
+		if i, ok := index[item.OrderUID]; ok {
+			orders[i].Items = append(orders[i].Items, item)
 		}
-		orders = append(orders, *order)
 	}
+	return nil
+}
 
-	return orders, nil
+// Ping проверяет соединение с базой данных.
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
 }
 
 func (s *Storage) Close() {
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"L0_project/internal/model"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SaveResult описывает, чем закончилось сохранение заказа.
+type SaveResult int
+
+const (
+	// SaveCreated — заказ сохранён впервые.
+	SaveCreated SaveResult = iota + 1
+	// SaveUnchanged — заказ уже сохранён с тем же содержимым либо его содержимое
+	// изменено через API; запись не изменялась.
+	SaveUnchanged
+	// SaveUpdated — заказ уже существовал с другим содержимым и был обновлён до новой версии.
+	SaveUpdated
+	// SaveStale — сообщение старше того, из которого сохранено текущее содержимое
+	// заказа (model.Order.SourceAt); запись не изменялась.
+	SaveStale
+)
+
+func (r SaveResult) String() string {
+	switch r {
+	case SaveCreated:
+		return "created"
+	case SaveUnchanged:
+		return "unchanged"
+	case SaveUpdated:
+		return "updated"
+	case SaveStale:
+		return "stale"
+	default:
+		return "unknown"
+	}
+}
+
+// orderHash вычисляет детерминированный хэш содержимого заказа.
+// Служебные поля (идентификаторы строк) в JSON не попадают, время приводится к UTC
+// и усекается до микросекунд: с такой точностью date_created передаёт Avro
+// и хранит Postgres, поэтому хэш не зависит от формата сообщения.
+// Статус и версия не входят в содержимое заказа: их ведёт сервис.
+func orderHash(order *model.Order) (string, error) {
+	normalized := *order
+	normalized.DateCreated = normalized.DateCreated.UTC().Truncate(time.Microsecond)
+	normalized.Status = ""
+	normalized.Version = 0
+
+	b, err := json.Marshal(&normalized)
+	if err != nil {
+		return "", fmt.Errorf("не удалось сериализовать заказ %s: %w", order.OrderUID, err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"L0_project/internal/codec"
+	"testing"
+	"time"
+)
+
+func TestOrderHashSameForAllFormats(t *testing.T) {
+	order := newTestOrder()
+	order.DateCreated = time.Date(2021, 11, 26, 9, 22, 19, 123456789, time.FixedZone("MSK", 3*60*60))
+	want, err := orderHash(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"json", "protobuf", "avro"} {
+		c, err := codec.ByName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := c.Marshal(order)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := c.Unmarshal(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := orderHash(decoded); err != nil || got != want {
+			t.Errorf("%s: хэш %s (%v), ожидался %s", name, got, err, want)
+		}
+	}
+}
+
+func TestOrderHashIgnoresServiceFields(t *testing.T) {
+	order := newTestOrder()
+	want, err := orderHash(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed := *order
+	changed.Status, changed.Version, changed.SourceAt = "delivered", 7, time.Now()
+	if got, _ := orderHash(&changed); got != want {
+		t.Error("статус, версия или время сообщения изменили хэш")
+	}
+	changed.Delivery.City = "Tel Aviv"
+	if got, _ := orderHash(&changed); got == want {
+		t.Error("изменение содержимого не изменило хэш")
+	}
+}
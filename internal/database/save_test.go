@@ -0,0 +1,145 @@
+package database
+
+import (
+	"L0_project/internal/model"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSaveOrderStaleRedelivery(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, db OrderStorage) {
+		base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		order := newTestOrder()
+		mustSave(t, db, fromKafka(order, base), SaveCreated)
+
+		newer := fromKafka(order, base.Add(time.Minute))
+		newer.Delivery.City = "Tel Aviv"
+		mustSave(t, db, newer, SaveUpdated)
+
+		// Запоздавшая доставка первого сообщения не откатывает новое содержимое,
+		// даже если date_created в нём не меняется.
+		mustSave(t, db, fromKafka(order, base), SaveStale)
+		if got := mustGet(t, db, order.OrderUID); got.Delivery.City != "Tel Aviv" || got.Version != 2 {
+			t.Errorf("после запоздавшего сообщения город %q, версия %d", got.Delivery.City, got.Version)
+		}
+
+		// Повтор последнего сообщения — дубликат, а не устаревшее сообщение.
+		mustSave(t, db, fromKafka(newer, newer.SourceAt), SaveUnchanged)
+
+		newest := fromKafka(order, base.Add(2*time.Minute))
+		mustSave(t, db, newest, SaveUpdated)
+		if got := mustGet(t, db, order.OrderUID); got.Delivery.City != order.Delivery.City || got.Version != 3 {
+			t.Errorf("после нового сообщения город %q, версия %d", got.Delivery.City, got.Version)
+		}
+	})
+}
+
+func TestSaveOrderWithoutSourceTimeUpdates(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, db OrderStorage) {
+		order := newTestOrder()
+		mustSave(t, db, fromKafka(order, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)), SaveCreated)
+
+		// Без времени сообщения порядок неизвестен — новое содержимое применяется.
+		changed := fromKafka(order, time.Time{})
+		changed.Delivery.City = "Tel Aviv"
+		mustSave(t, db, changed, SaveUpdated)
+
+		// Время последнего сообщения сохраняется, и более старое по-прежнему отбрасывается.
+		mustSave(t, db, fromKafka(order, time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC)), SaveStale)
+	})
+}
+
+// amendCity меняет город доставки через API.
+func amendCity(t *testing.T, db OrderStorage, orderUID, city string) *model.Order {
+	t.Helper()
+	version := mustGet(t, db, orderUID).Version
+	order, err := db.AmendOrder(context.Background(), orderUID, version, Amendment{
+		Action: model.AuditAmendDelivery,
+		Actor:  "test",
+		Apply: func(order *model.Order) error {
+			order.Delivery.City = city
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("AmendOrder: %v", err)
+	}
+	return order
+}
+
+func TestSaveOrderAfterAmendment(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, db OrderStorage) {
+		base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		order := newTestOrder()
+		mustSave(t, db, fromKafka(order, base), SaveCreated)
+		amended := amendCity(t, db, order.OrderUID, "Haifa")
+
+		// Повторная доставка исходного сообщения не отменяет изменение.
+		mustSave(t, db, fromKafka(order, base), SaveUnchanged)
+
+		// Новое содержимое из Kafka — конфликт, а не молчаливый пропуск.
+		upstream := fromKafka(order, base.Add(time.Minute))
+		upstream.Delivery.Address = "Ploshad Mira 16"
+		_, err := db.SaveOrder(context.Background(), upstream)
+		if !errors.Is(err, ErrConflict) || !errors.Is(err, ErrOrderAmended) {
+			t.Fatalf("SaveOrder = %v, ожидался конфликт ErrOrderAmended", err)
+		}
+
+		got := mustGet(t, db, order.OrderUID)
+		if got.Delivery.City != "Haifa" || got.Delivery.Address != order.Delivery.Address || got.Version != amended.Version {
+			t.Errorf("после конфликта город %q, адрес %q, версия %d", got.Delivery.City, got.Delivery.Address, got.Version)
+		}
+	})
+}
+
+func TestSaveOrderAfterStatusAmendment(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, db OrderStorage) {
+		base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		order := newTestOrder()
+		mustSave(t, db, fromKafka(order, base), SaveCreated)
+		_, err := db.AmendOrder(context.Background(), order.OrderUID, 1, Amendment{
+			Action: model.AuditCancel,
+			Actor:  "test",
+			Apply: func(order *model.Order) error {
+				order.Status = model.StatusCancelled
+				return nil
+			},
+		})
+		if err != nil {
+			t.Fatalf("AmendOrder: %v", err)
+		}
+
+		// Смена статуса не меняет содержимое и обновлений из Kafka не блокирует.
+		upstream := fromKafka(order, base.Add(time.Minute))
+		upstream.Delivery.Address = "Ploshad Mira 16"
+		mustSave(t, db, upstream, SaveUpdated)
+		if got := mustGet(t, db, order.OrderUID); got.Status != model.StatusCancelled || got.Delivery.Address != "Ploshad Mira 16" {
+			t.Errorf("статус %s, адрес %q", got.Status, got.Delivery.Address)
+		}
+	})
+}
+
+func TestSaveOrdersIsAtomic(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, db OrderStorage) {
+		base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		amended := newTestOrder()
+		mustSave(t, db, fromKafka(amended, base), SaveCreated)
+		amendCity(t, db, amended.OrderUID, "Haifa")
+
+		fresh := newTestOrder()
+		conflicting := fromKafka(amended, base.Add(time.Minute))
+		conflicting.Delivery.Address = "Ploshad Mira 16"
+		results, err := db.SaveOrders(context.Background(), []*model.Order{fromKafka(fresh, base), conflicting})
+		if !errors.Is(err, ErrConflict) || results != nil {
+			t.Fatalf("SaveOrders = %v, %v, ожидался конфликт", results, err)
+		}
+
+		// Пакет сохраняется целиком или не сохраняется вовсе.
+		if _, err := db.GetOrder(context.Background(), fresh.OrderUID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetOrder нового заказа из отклонённого пакета = %v, ожидался ErrNotFound", err)
+		}
+		mustSave(t, db, fromKafka(fresh, base), SaveCreated)
+	})
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"L0_project/internal/metrics"
+	"L0_project/internal/model"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Источники совпадений поиска (SearchHighlight.Source).
+const (
+	SearchSourceOrder    = "order"
+	SearchSourceDelivery = "delivery"
+	SearchSourceItem     = "item"
+)
+
+// Маркеры подсветки совпавших слов в SearchHighlight.Fragment.
+const (
+	HighlightStart = "<mark>"
+	HighlightStop  = "</mark>"
+)
+
+// SearchHighlight — фрагмент совпавшего текста заказа с подсвеченными словами.
+type SearchHighlight struct {
+	// Source — где найдено совпадение: order (order_uid, трек-номер, покупатель),
+	// delivery (получатель и адрес) или item (название и бренд товара).
+	Source   string `json:"source"`
+	Fragment string `json:"fragment"`
+}
+
+// SearchHit — заказ, найденный SearchOrders.
+type SearchHit struct {
+	Order      model.Order       `json:"order"`
+	Rank       float64           `json:"rank"`
+	Highlights []SearchHighlight `json:"highlights"`
+}
+
+// searchQuery ищет заказы по tsvector-колонкам orders, deliveries и items
+// (websearch_to_tsquery: слова, "фраза", -исключение) и по сходству слов
+// с search_text (pg_trgm, <%), которое находит фрагменты слов и опечатки.
+// Ранг совпадения — ts_rank плюс word_similarity; ранг заказа — лучшее из его
+// совпадений. Подсветка через ts_headline строится только для найденной страницы.
+// Совпадение только по сходству возвращается без подсветки — началом текста.
+const searchQuery = `
+        WITH q AS (
+            SELECT websearch_to_tsquery('simple', $1) AS tsq, $1::text AS raw
+        ),
+        matches AS (
+            SELECT o.order_uid, 'order' AS source, o.search_text AS doc,
+                   ts_rank(o.search_vector, q.tsq) + word_similarity(q.raw, o.search_text) AS rank
+            FROM orders o, q
+            WHERE o.search_vector @@ q.tsq OR q.raw <% o.search_text
+            UNION ALL
+            SELECT o.order_uid, 'delivery', d.search_text,
+                   ts_rank(d.search_vector, q.tsq) + word_similarity(q.raw, d.search_text)
+            FROM deliveries d
+            JOIN orders o ON o.delivery_id = d.id, q
+            WHERE d.search_vector @@ q.tsq OR q.raw <% d.search_text
+            UNION ALL
+            SELECT i.order_uid, 'item', i.search_text,
+                   ts_rank(i.search_vector, q.tsq) + word_similarity(q.raw, i.search_text)
+            FROM items i, q
+            WHERE i.search_vector @@ q.tsq OR q.raw <% i.search_text
+        ),
+        top AS (
+            SELECT order_uid, max(rank) AS rank
+            FROM matches
+            GROUP BY order_uid
+            ORDER BY rank DESC, order_uid
+            LIMIT $2
+        )
+        SELECT t.order_uid, t.rank, m.source,
+               ts_headline('simple', m.doc, q.tsq, $3) AS fragment
+        FROM top t
+        JOIN matches m USING (order_uid), q
+        ORDER BY t.rank DESC, t.order_uid, m.rank DESC`
+
+// headlineOptions — параметры ts_headline: не больше двух коротких фрагментов.
+var headlineOptions = fmt.Sprintf("StartSel=%s, StopSel=%s, MaxFragments=2, MaxWords=12, MinWords=4", HighlightStart, HighlightStop)
+
+// SearchOrders ищет заказы по фрагментам имени и адреса получателя, email, телефона,
+// названия и бренда товара, трек-номера и идентификаторов и возвращает не больше
+// limit заказов по убыванию релевантности (см. searchQuery).
+func (s *Storage) SearchOrders(ctx context.Context, query string, limit int) (_ []SearchHit, err error) {
+	defer metrics.ObserveDBQuery("SearchOrders", time.Now(), &err)
+	defer classify(&err)
+
+	var rows []struct {
+		OrderUID string  `db:"order_uid"`
+		Rank     float64 `db:"rank"`
+		Source   string  `db:"source"`
+		Fragment string  `db:"fragment"`
+	}
+	if err := s.db.SelectContext(ctx, &rows, searchQuery, query, limit, headlineOptions); err != nil {
+		return nil, fmt.Errorf("не удалось выполнить поиск заказов: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	var hits []SearchHit
+	index := make(map[string]int)
+	for _, row := range rows {
+		i, ok := index[row.OrderUID]
+		if !ok {
+			i = len(hits)
+			index[row.OrderUID] = i
+			hits = append(hits, SearchHit{Order: model.Order{OrderUID: row.OrderUID}, Rank: row.Rank})
+		}
+		hits[i].Highlights = append(hits[i].Highlights, SearchHighlight{Source: row.Source, Fragment: row.Fragment})
+	}
+
+	uids := make([]string, len(hits))
+	for i, hit := range hits {
+		uids[i] = hit.Order.OrderUID
+	}
+	orders, err := s.selectOrders(ctx, orderSelect+` WHERE o.order_uid = ANY($1)`, pq.Array(uids))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить найденные заказы: %w", err)
+	}
+	found := make(map[string]bool, len(orders))
+	for _, order := range orders {
+		hits[index[order.OrderUID]].Order = order
+		found[order.OrderUID] = true
+	}
+	// Заказ мог быть удалён между запросами.
+	result := hits[:0]
+	for _, hit := range hits {
+		if found[hit.Order.OrderUID] {
+			result = append(result, hit)
+		}
+	}
+	return result, nil
+}
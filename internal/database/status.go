@@ -0,0 +1,88 @@
+package database
+
+import (
+	"L0_project/internal/metrics"
+	"L0_project/internal/model"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// UpdateStatus переводит заказ в статус to и записывает переход в историю.
+// Если заказ уже в статусе to, ничего не меняется и возвращается false: повторная
+// доставка события статуса не считается ошибкой. Недопустимый переход возвращает
+// ошибку, оборачивающую model.ErrInvalidTransition.
+func (s *Storage) UpdateStatus(ctx context.Context, orderUID string, to model.OrderStatus, source string) (_ bool, err error) {
+	defer metrics.ObserveDBQuery("UpdateStatus", time.Now(), &err)
+	defer classify(&err)
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback()
+
+	var from model.OrderStatus
+	err = tx.GetContext(ctx, &from, `SELECT status FROM orders WHERE order_uid = $1 FOR UPDATE`, orderUID)
+	if err != nil {
+		return false, fmt.Errorf("не удалось получить статус заказа %s: %w", orderUID, err)
+	}
+	if from == to {
+		return false, nil
+	}
+	if err := from.Transition(to); err != nil {
+		return false, fmt.Errorf("заказ %s: %w", orderUID, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE orders SET status = $2, version = version + 1, updated_at = now() WHERE order_uid = $1`, orderUID, to)
+	if err != nil {
+		return false, fmt.Errorf("не удалось обновить статус заказа %s: %w", orderUID, err)
+	}
+	if err := insertStatusChange(ctx, tx, model.StatusChange{OrderUID: orderUID, From: from, To: to, Source: source}); err != nil {
+		return false, err
+	}
+	if err := notifyOrderChanged(ctx, tx, orderUID); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+	}
+	return true, nil
+}
+
+// GetStatusHistory возвращает историю статусов заказа в хронологическом порядке.
+func (s *Storage) GetStatusHistory(ctx context.Context, orderUID string) (_ []model.StatusChange, err error) {
+	defer metrics.ObserveDBQuery("GetStatusHistory", time.Now(), &err)
+	defer classify(&err)
+
+	var history []model.StatusChange
+	query := `SELECT order_uid, COALESCE(from_status, '') AS from_status, to_status, source, changed_at
+              FROM order_status_history
+              WHERE order_uid = $1
+              ORDER BY id`
+	if err := s.db.SelectContext(ctx, &history, query, orderUID); err != nil {
+		return nil, fmt.Errorf("не удалось получить историю статусов заказа %s: %w", orderUID, err)
+	}
+	// У каждого заказа есть хотя бы запись о создании, пустая история значит, что заказа нет.
+	if len(history) == 0 {
+		return nil, fmt.Errorf("не удалось получить историю статусов заказа %s: %w", orderUID, sql.ErrNoRows)
+	}
+	return history, nil
+}
+
+func insertStatusChange(ctx context.Context, tx *sqlx.Tx, change model.StatusChange) error {
+	var from sql.NullString
+	if change.From != "" {
+		from = sql.NullString{String: string(change.From), Valid: true}
+	}
+	_, err := tx.ExecContext(ctx, `INSERT INTO order_status_history (order_uid, from_status, to_status, source) VALUES ($1, $2, $3, $4)`,
+		change.OrderUID, from, change.To, change.Source)
+	if err != nil {
+		return fmt.Errorf("не удалось записать историю статусов заказа %s: %w", change.OrderUID, err)
+	}
+	return nil
+}
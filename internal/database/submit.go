@@ -0,0 +1,103 @@
+package database
+
+import (
+	"L0_project/internal/metrics"
+	"L0_project/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// IdempotencyKeyTTL — сколько хранится ключ идемпотентности. После этого ключ
+// можно использовать заново, а строку — удалить по created_at.
+const IdempotencyKeyTTL = 24 * time.Hour
+
+// ErrOrderExists возвращается, если через API создаётся заказ с order_uid уже
+// сохранённого заказа, но с другим содержимым.
+var ErrOrderExists = errors.New("заказ с таким order_uid уже существует с другим содержимым")
+
+// ErrIdempotencyKeyReused возвращается, если ключ идемпотентности уже использован
+// для другого заказа или для заказа с другим содержимым.
+var ErrIdempotencyKeyReused = errors.New("ключ идемпотентности уже использован с другим заказом")
+
+// SubmitOrder сохраняет заказ, принятый через HTTP API, так же как SaveOrder, но
+// с источником model.StatusSourceAPI в истории статусов. API только создаёт заказы:
+// повтор того же заказа возвращает SaveUnchanged, а заказ с тем же order_uid
+// и другим содержимым — ошибку, оборачивающую ErrOrderExists. Непустой key запоминается
+// в той же транзакции: повтор с тем же ключом и тем же заказом возвращает результат
+// первого сохранения и ничего не меняет.
+func (s *Storage) SubmitOrder(ctx context.Context, order *model.Order, key string) (_ SaveResult, err error) {
+	defer metrics.ObserveDBQuery("SubmitOrder", time.Now(), &err)
+	defer classify(&err)
+
+	hash, err := orderHash(order)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Сначала ключ, затем заказ: так блокировки всегда берутся в одном порядке,
+	// а два запроса с одним ключом и разными заказами не сохранят оба заказа.
+	if key != "" {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('idempotency:' || $1))`, key); err != nil {
+			return 0, fmt.Errorf("не удалось заблокировать ключ идемпотентности: %w", err)
+		}
+
+		var prev struct {
+			OrderUID    string     `db:"order_uid"`
+			PayloadHash string     `db:"payload_hash"`
+			Result      SaveResult `db:"result"`
+		}
+		err := tx.GetContext(ctx, &prev, `SELECT order_uid, payload_hash, result FROM idempotency_keys WHERE key = $1 AND created_at > $2`,
+			key, time.Now().Add(-IdempotencyKeyTTL))
+		switch {
+		case err == nil:
+			if prev.OrderUID != order.OrderUID || prev.PayloadHash != hash {
+				return 0, ErrIdempotencyKeyReused
+			}
+			err := tx.QueryRowxContext(ctx, `SELECT status, version FROM orders WHERE order_uid = $1`, order.OrderUID).Scan(&order.Status, &order.Version)
+			if err != nil {
+				return 0, fmt.Errorf("не удалось получить статус заказа %s: %w", order.OrderUID, err)
+			}
+			return prev.Result, nil
+		case !errors.Is(err, sql.ErrNoRows):
+			return 0, fmt.Errorf("не удалось проверить ключ идемпотентности: %w", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, order.OrderUID); err != nil {
+		return 0, fmt.Errorf("не удалось заблокировать заказ %s: %w", order.OrderUID, err)
+	}
+
+	result, err := saveOrderTx(ctx, tx, order, hash, model.StatusSourceAPI)
+	if err != nil {
+		return 0, err
+	}
+
+	if key != "" {
+		// Просроченная строка с тем же ключом перезаписывается.
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO idempotency_keys (key, order_uid, payload_hash, result)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (key) DO UPDATE
+			SET order_uid = EXCLUDED.order_uid, payload_hash = EXCLUDED.payload_hash, result = EXCLUDED.result, created_at = now()`,
+			key, order.OrderUID, hash, result)
+		if err != nil {
+			return 0, fmt.Errorf("не удалось сохранить ключ идемпотентности: %w", err)
+		}
+	} else if result == SaveUnchanged {
+		return result, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,137 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check проверяет одну зависимость сервиса. nil означает, что зависимость готова.
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker обслуживает эндпоинты /healthz и /readyz.
+type Checker struct {
+	timeout time.Duration
+	checks  []namedCheck
+}
+
+// New создает Checker. timeout ограничивает время выполнения всех проверок готовности.
+func New(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add регистрирует проверку готовности. Вызывается до запуска HTTP-сервера.
+func (c *Checker) Add(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// CheckResult — результат одной проверки в ответе /readyz.
+type CheckResult struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Report — тело ответа /readyz.
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Liveness отвечает 200, пока процесс способен обслуживать HTTP-запросы.
+// Зависимости не проверяются: их недоступность не повод перезапускать процесс.
+func (c *Checker) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": StatusOK})
+}
+
+// Readiness выполняет все проверки параллельно и отвечает 200, если все они прошли,
+// иначе 503. В теле возвращается результат по каждой зависимости.
+func (c *Checker) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := c.Run(r.Context())
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+// Run выполняет все проверки готовности.
+func (c *Checker) Run(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(c.checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, nc := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := nc.check(ctx)
+			res := CheckResult{Status: StatusOK, DurationMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				res.Status = StatusFail
+				res.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[nc.name] = res
+			if err != nil {
+				report.Status = StatusFail
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+// Flag — проверка, которая проходит после вызова Set. Используется для разовых
+// этапов запуска, например прогрева кэша.
+type Flag struct {
+	done atomic.Bool
+	err  error
+}
+
+// NewFlag создает флаг; err возвращается проверкой, пока флаг не установлен.
+func NewFlag(err error) *Flag {
+	return &Flag{err: err}
+}
+
+// Set отмечает этап как завершённый.
+func (f *Flag) Set() {
+	f.done.Store(true)
+}
+
+// Check реализует Check.
+func (f *Flag) Check(context.Context) error {
+	if f.done.Load() {
+		return nil
+	}
+	if f.err == nil {
+		return errors.New("не готово")
+	}
+	return f.err
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,208 @@
+// Package ingest принимает заказы: разбор, проверка, сохранение и обновление кэша.
+// Его используют и consumer Kafka, и HTTP API, поэтому правила приёма заказа
+// в обоих каналах одинаковые.
+package ingest
+
+import (
+	"L0_project/internal/cache"
+	"L0_project/internal/codec"
+	"L0_project/internal/database"
+	"L0_project/internal/model"
+	"L0_project/internal/rules"
+	"L0_project/internal/schema"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Этапы приёма, на которых заказ может быть отклонён.
+const (
+	StageParse    = "parse"
+	StageValidate = "validate"
+	StageRules    = "rules"
+)
+
+// FieldError — ошибка в поле заказа.
+type FieldError struct {
+	// Field — путь к полю в JSON, например delivery.phone или items[0].price.
+	Field string `json:"field"`
+	// Rule — тег validator или имя бизнес-правила.
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// RejectedError — заказ отклонён при разборе или проверке. Повтор не поможет.
+type RejectedError struct {
+	Stage string
+	// Fields пуст, если ошибка не относится к отдельным полям (например, битый JSON).
+	Fields []FieldError
+	Err    error
+}
+
+func (e *RejectedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RejectedError) Unwrap() error {
+	return e.Err
+}
+
+// Service — приём заказа из любого канала.
+type Service struct {
+	db       database.OrderStorage
+	cache    cache.OrderCache
+	validate *validator.Validate
+	schemas  *schema.Registry
+	rules    *rules.Engine
+}
+
+// NewService создает сервис приёма заказов. orderRules может быть nil — тогда
+// бизнес-правила не проверяются.
+func NewService(db database.OrderStorage, cache cache.OrderCache, orderRules *rules.Engine) *Service {
+	validate := validator.New()
+	// В ошибках поля называются так же, как в JSON.
+	validate.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return &Service{
+		db:       db,
+		cache:    cache,
+		validate: validate,
+		schemas:  schema.Default(),
+		rules:    orderRules,
+	}
+}
+
+// Decode разбирает заказ в формате contentType (см. пакет codec); пустой
+// contentType означает JSON. JSON разбирается реестром версий схемы, version
+// для других форматов не используется.
+func (s *Service) Decode(contentType string, version int, data []byte) (*model.Order, error) {
+	format := codec.JSON
+	if contentType != "" {
+		var err error
+		if format, err = codec.ByContentType(contentType); err != nil {
+			return nil, &RejectedError{Stage: StageParse, Err: err}
+		}
+	}
+
+	var order *model.Order
+	var err error
+	if format == codec.JSON {
+		order, err = s.schemas.Decode(version, data)
+	} else {
+		order, err = format.Unmarshal(data)
+	}
+	if err != nil {
+		return nil, &RejectedError{Stage: StageParse, Err: err}
+	}
+	return order, nil
+}
+
+// Validate проверяет заказ тегами validate модели, затем бизнес-правилами.
+func (s *Service) Validate(order *model.Order) error {
+	if err := s.validate.Struct(order); err != nil {
+		rejected := &RejectedError{Stage: StageValidate, Err: err}
+		var verrs validator.ValidationErrors
+		if errors.As(err, &verrs) {
+			for _, fe := range verrs {
+				rejected.Fields = append(rejected.Fields, FieldError{
+					Field:   fieldPath(fe.Namespace()),
+					Rule:    fe.Tag(),
+					Message: fieldMessage(fe),
+				})
+			}
+		}
+		return rejected
+	}
+
+	if s.rules != nil {
+		if err := s.rules.Validate(order); err != nil {
+			rejected := &RejectedError{Stage: StageRules, Err: err}
+			var verr *rules.ViolationError
+			if errors.As(err, &verr) {
+				for _, v := range verr.Violations {
+					rejected.Fields = append(rejected.Fields, FieldError{Field: v.Field, Rule: v.Rule, Message: v.Message})
+				}
+			}
+			return rejected
+		}
+	}
+	return nil
+}
+
+// Save сохраняет заказ, полученный из Kafka, и обновляет кэш.
+func (s *Service) Save(ctx context.Context, order *model.Order) (database.SaveResult, error) {
+	result, err := s.db.SaveOrder(ctx, order)
+	if err != nil {
+		return 0, err
+	}
+	s.cached(order, result)
+	return result, nil
+}
+
+// SaveBatch сохраняет пакет заказов из Kafka одной транзакцией и обновляет кэш.
+func (s *Service) SaveBatch(ctx context.Context, orders []*model.Order) ([]database.SaveResult, error) {
+	results, err := s.db.SaveOrders(ctx, orders)
+	if err != nil {
+		return nil, err
+	}
+	for i, order := range orders {
+		s.cached(order, results[i])
+	}
+	return results, nil
+}
+
+// Submit сохраняет заказ, полученный через HTTP API, с ключом идемпотентности
+// (пустой — без ключа) и обновляет кэш.
+func (s *Service) Submit(ctx context.Context, order *model.Order, idempotencyKey string) (database.SaveResult, error) {
+	result, err := s.db.SubmitOrder(ctx, order, idempotencyKey)
+	if err != nil {
+		return 0, err
+	}
+	s.cached(order, result)
+	return result, nil
+}
+
+// cached кладёт сохранённый заказ в кэш. Неизменённый или устаревший заказ
+// в базу не записан, и кэш не трогается.
+func (s *Service) cached(order *model.Order, result database.SaveResult) {
+	if result == database.SaveCreated || result == database.SaveUpdated {
+		s.cache.Add(order.OrderUID, order)
+	}
+}
+
+// fieldPath убирает из пути validator имя корневой структуры: Order.delivery.phone -> delivery.phone.
+func fieldPath(namespace string) string {
+	if _, path, ok := strings.Cut(namespace, "."); ok {
+		return path
+	}
+	return namespace
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "обязательное поле"
+	case "min", "gte":
+		if fe.Kind() == reflect.Slice {
+			return fmt.Sprintf("должно содержать не меньше %s элементов", fe.Param())
+		}
+		return fmt.Sprintf("должно быть не меньше %s", fe.Param())
+	case "uuid4":
+		return "должно быть UUID версии 4"
+	case "e164":
+		return "должен быть номером телефона в формате E.164"
+	case "email":
+		return "должен быть адресом электронной почты"
+	default:
+		return fmt.Sprintf("не проходит проверку %q", fe.Tag())
+	}
+}
@@ -0,0 +1,141 @@
+package kafka
+
+import (
+	"L0_project/internal/database"
+	"L0_project/internal/metrics"
+	"L0_project/internal/model"
+	"context"
+	"log/slog"
+	"strconv"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// pendingOrder — разобранный и проверенный заказ пакета, ожидающий сохранения.
+type pendingOrder struct {
+	m     kafka.Message
+	order *model.Order
+	log   *slog.Logger
+}
+
+// runBatches читает сообщения пакетами до BatchSize штук или до истечения
+// BatchTimeout после первого сообщения, сохраняет заказы пакета одной транзакцией
+// и подтверждает offset всего пакета.
+func (c *Consumer) runBatches(ctx context.Context) {
+	for {
+		batch, err := c.fetchBatch(ctx)
+		if err != nil {
+			return
+		}
+		metrics.ConsumerBatchSize.Observe(float64(len(batch)))
+
+		if err := c.handleBatch(ctx, batch); err != nil {
+			// Контекст отменён: пакет не подтверждается и будет прочитан повторно.
+			return
+		}
+
+		if err := c.reader.CommitMessages(ctx, batch...); err != nil {
+			c.log.Error("не удалось подтвердить пакет сообщений", "error", err, "size", len(batch))
+		}
+	}
+}
+
+// fetchBatch ждёт первое сообщение без ограничения по времени, а остальные —
+// не дольше batchTimeout. Ошибка возвращается только при отмене контекста.
+func (c *Consumer) fetchBatch(ctx context.Context) ([]kafka.Message, error) {
+	m, err := c.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	batch := make([]kafka.Message, 1, c.batchSize)
+	batch[0] = m
+
+	fetchCtx, cancel := context.WithTimeout(ctx, c.batchTimeout)
+	defer cancel()
+	for len(batch) < c.batchSize {
+		m, err := c.reader.FetchMessage(fetchCtx)
+		if err != nil {
+			// Таймаут добора или ошибка брокера: обрабатываем то, что уже получено.
+			break
+		}
+		c.ready.fetched()
+		batch = append(batch, m)
+	}
+	return batch, ctx.Err()
+}
+
+// handleBatch обрабатывает пакет в порядке сообщений. Заказы копятся и сохраняются
+// вместе; перед событием статуса накопленные заказы сохраняются, чтобы событие
+// не обогнало заказ, к которому относится. Ошибка возвращается только при отмене контекста.
+func (c *Consumer) handleBatch(ctx context.Context, batch []kafka.Message) error {
+	var pending []pendingOrder
+	for _, m := range batch {
+		metrics.ConsumerLag.WithLabelValues(strconv.Itoa(m.Partition)).Set(float64(m.HighWaterMark - m.Offset - 1))
+
+		if eventType(m) == EventOrderStatus {
+			if err := c.saveBatch(ctx, pending); err != nil {
+				return err
+			}
+			pending = nil
+			if err := c.handleStatusEvent(ctx, m); err != nil {
+				return err
+			}
+			continue
+		}
+
+		order, log, err := c.decodeOrder(ctx, m)
+		if err != nil {
+			return err
+		}
+		if order != nil {
+			pending = append(pending, pendingOrder{m: m, order: order, log: log})
+		}
+	}
+	return c.saveBatch(ctx, pending)
+}
+
+// saveBatch сохраняет заказы одной транзакцией. Временные ошибки повторяются для
+// всего пакета. Если один из заказов не может быть сохранён (Postgres отклонил данные,
+// конфликт с заказом, изменённым через API), пакет делится пополам, пока ошибочный
+// заказ не останется один: он уходит в dead-letter topic, остальные сохраняются.
+func (c *Consumer) saveBatch(ctx context.Context, batch []pendingOrder) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	orders := make([]*model.Order, len(batch))
+	for i, p := range batch {
+		orders[i] = p.order
+	}
+
+	var results []database.SaveResult
+	err := c.withRetry(ctx, c.log, func() error {
+		var err error
+		results, err = c.ingest.SaveBatch(ctx, orders)
+		return err
+	})
+
+	switch {
+	case err == nil:
+		for i, p := range batch {
+			c.saved(p.log, p.order, results[i])
+		}
+		return nil
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case len(batch) > 1 && permanent(err):
+		c.log.Warn("пакет не сохранён, делим пополам", "error", err, "size", len(batch))
+		mid := len(batch) / 2
+		if err := c.saveBatch(ctx, batch[:mid]); err != nil {
+			return err
+		}
+		return c.saveBatch(ctx, batch[mid:])
+	default:
+		for _, p := range batch {
+			if err := c.saveFailed(ctx, p.log, p.m, err); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
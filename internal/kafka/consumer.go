@@ -3,75 +3,302 @@ package kafka
 import (
 	"L0_project/internal/cache"
 	"L0_project/internal/database"
+	"L0_project/internal/ingest"
+	"L0_project/internal/metrics"
 	"L0_project/internal/model"
 	"context"
-	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/segmentio/kafka-go"
 )
 
+// ConsumerConfig содержит параметры подключения consumer к Kafka.
+type ConsumerConfig struct {
+	Brokers         []string
+	Topic           string
+	GroupID         string
+	DeadLetterTopic string
+	// Retry управляет повторными попытками сохранения заказа в базу данных.
+	Retry RetryPolicy
+	// BatchSize — сколько сообщений обрабатывать одним пакетом; 0 или 1 — по одному.
+	BatchSize int
+	// BatchTimeout — сколько ждать добора пакета после первого сообщения.
+	// По умолчанию 100 мс.
+	BatchTimeout time.Duration
+	// Workers — число параллельных обработчиков; 0 или 1 — обработка в одной горутине.
+	Workers int
+	// DrainTimeout — сколько при остановке дообрабатывать уже полученные сообщения
+	// в параллельном режиме. По умолчанию 10 секунд.
+	DrainTimeout time.Duration
+	// FetchStaleness — сколько времени без запросов к брокеру consumer считается готовым.
+	// По умолчанию одна минута.
+	FetchStaleness time.Duration
+}
+
 type Consumer struct {
 	reader   *kafka.Reader
+	dlq      *DeadLetterWriter
 	db       database.OrderStorage
 	cache    cache.OrderCache
+	ingest   *ingest.Service
 	validate *validator.Validate
+	retry    RetryPolicy
+	log      *slog.Logger
+
+	batchSize    int
+	batchTimeout time.Duration
+	workers      int
+	drainTimeout time.Duration
+
+	ready     readiness
+	staleness time.Duration
 }
 
-func NewConsumer(brokers []string, topic, groupID string, db database.OrderStorage, cache cache.OrderCache) *Consumer {
+// NewConsumer создает consumer. Заказы разбираются, проверяются и сохраняются
+// сервисом приёма ingest — так же, как заказы из HTTP API.
+func NewConsumer(cfg ConsumerConfig, db database.OrderStorage, cache cache.OrderCache, ingest *ingest.Service, log *slog.Logger) *Consumer {
 	r := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:  brokers,
-		GroupID:  groupID,
-		Topic:    topic,
+		Brokers:  cfg.Brokers,
+		GroupID:  cfg.GroupID,
+		Topic:    cfg.Topic,
 		MinBytes: 10e3,
 		MaxBytes: 10e6,
 	})
-	return &Consumer{reader: r, db: db, cache: cache, validate: validator.New()}
+	dlq := NewDeadLetterWriter(cfg.Brokers, cfg.DeadLetterTopic)
+	if cfg.FetchStaleness <= 0 {
+		cfg.FetchStaleness = time.Minute
+	}
+	if cfg.BatchTimeout <= 0 {
+		cfg.BatchTimeout = 100 * time.Millisecond
+	}
+	if cfg.DrainTimeout <= 0 {
+		cfg.DrainTimeout = 10 * time.Second
+	}
+	return &Consumer{
+		reader:    r,
+		dlq:       dlq,
+		db:        db,
+		cache:     cache,
+		ingest:    ingest,
+		validate:  validator.New(),
+		retry:     cfg.Retry,
+		log:       log.With("component", "kafka_consumer", "topic", cfg.Topic),
+		staleness: cfg.FetchStaleness,
+
+		batchSize:    cfg.BatchSize,
+		batchTimeout: cfg.BatchTimeout,
+		workers:      cfg.Workers,
+		drainTimeout: cfg.DrainTimeout,
+	}
 }
 
+// Start читает сообщения до отмены контекста. Каждое сообщение доводится до конечного
+// состояния (сохранено или отправлено в dead-letter topic) до перехода к следующему,
+// поэтому подтверждение offset никогда не перескакивает необработанное сообщение.
+// При Workers > 1 сообщения обрабатываются параллельно (см. runParallel),
+// при BatchSize > 1 — пакетами (см. runBatches).
 func (c *Consumer) Start(ctx context.Context) {
-	log.Println("Kafka запущен...")
+	c.log.Info("Kafka запущен", "batch_size", c.batchSize, "workers", c.workers)
+	defer func() {
+		c.log.Info("Завершение работы Kafka")
+		c.reader.Close()
+		c.dlq.Close()
+	}()
+
+	switch {
+	case c.workers > 1:
+		c.runParallel(ctx)
+		return
+	case c.batchSize > 1:
+		c.runBatches(ctx)
+		return
+	}
+
 	for {
-		select {
-		case <-ctx.Done():
-			log.Println("Завершение работы Kafka...")
-			c.reader.Close()
+		m, err := c.fetch(ctx)
+		if err != nil {
 			return
-		default:
-			m, err := c.reader.FetchMessage(ctx)
-			if err != nil {
-				log.Printf("не удалось получить сообщение: %v", err)
-				continue
-			}
-
-			var order model.Order
-			if err := json.Unmarshal(m.Value, &order); err != nil {
-				log.Printf("не удалось разобрать сообщение: %v. Сообщение: %s", err, string(m.Value))
-				c.reader.CommitMessages(ctx, m)
-				continue
-			}
-
-			if c.validate != nil {
-				if err := c.validate.Struct(&order); err != nil {
-					log.Printf("невалидные данные в заказе %s: %v", order.OrderUID, err)
-					c.reader.CommitMessages(ctx, m)
-					continue
-				}
-			}
-
-			if err := c.db.SaveOrder(ctx, &order); err != nil {
-				log.Printf("не удалось сохранить заказ %s в базу данных: %v", order.OrderUID, err)
-				continue
-			}
-
-			log.Printf("Заказ %s успешно сохранен в базу данных", order.OrderUID)
-			c.cache.Add(order.OrderUID, &order)
-			log.Printf("Заказ %s успешно закэширован", order.OrderUID)
-
-			if err := c.reader.CommitMessages(ctx, m); err != nil {
-				log.Printf("не удалось подтвердить сообщение: %v", err)
-			}
+		}
+
+		if err := c.handleMessage(ctx, m); err != nil {
+			// Контекст отменён: сообщение не подтверждается и будет прочитано повторно.
+			return
+		}
+
+		if err := c.reader.CommitMessages(ctx, m); err != nil {
+			c.messageLogger(m, string(m.Key)).Error("не удалось подтвердить сообщение", "error", err)
+		}
+	}
+}
+
+// fetch ждёт следующее сообщение, повторяя попытки после ошибок брокера.
+// Ошибка возвращается только при отмене контекста.
+func (c *Consumer) fetch(ctx context.Context) (kafka.Message, error) {
+	for {
+		m, err := c.reader.FetchMessage(ctx)
+		if err == nil {
+			c.ready.fetched()
+			return m, nil
+		}
+		if ctx.Err() != nil {
+			return kafka.Message{}, ctx.Err()
+		}
+		c.log.Error("не удалось получить сообщение", "error", err)
+		if err := sleep(ctx, c.retry.Backoff(1)); err != nil {
+			return kafka.Message{}, err
+		}
+	}
+}
+
+// messageLogger возвращает логгер с полями, идентифицирующими сообщение.
+func (c *Consumer) messageLogger(m kafka.Message, orderUID string) *slog.Logger {
+	return c.log.With("order_uid", orderUID, "partition", m.Partition, "offset", m.Offset)
+}
+
+// handleMessage разбирает, валидирует и сохраняет заказ. Ошибка возвращается только
+// при отмене контекста; во всех остальных случаях сообщение можно подтверждать.
+func (c *Consumer) handleMessage(ctx context.Context, m kafka.Message) error {
+	metrics.ConsumerLag.WithLabelValues(strconv.Itoa(m.Partition)).Set(float64(m.HighWaterMark - m.Offset - 1))
+
+	if eventType(m) == EventOrderStatus {
+		return c.handleStatusEvent(ctx, m)
+	}
+
+	order, log, err := c.decodeOrder(ctx, m)
+	if err != nil || order == nil {
+		return err
+	}
+
+	var result database.SaveResult
+	err = c.withRetry(ctx, log, func() error {
+		var err error
+		result, err = c.ingest.Save(ctx, order)
+		return err
+	})
+	if err != nil {
+		return c.saveFailed(ctx, log, m, err)
+	}
+
+	c.saved(log, order, result)
+	return nil
+}
+
+// decodeOrder разбирает заказ в формате из заголовков content-type и x-schema-version
+// и проверяет его тегами и бизнес-правилами. Отклонённое сообщение отправляется
+// в dead-letter topic, и возвращается nil-заказ.
+// Ошибка возвращается только при отмене контекста.
+func (c *Consumer) decodeOrder(ctx context.Context, m kafka.Message) (*model.Order, *slog.Logger, error) {
+	contentType, _ := header(m, HeaderContentType)
+	version, err := schemaVersion(m)
+	var order *model.Order
+	if err == nil {
+		order, err = c.ingest.Decode(contentType, version, m.Value)
+	}
+	if err != nil {
+		// order_uid неизвестен, используем ключ сообщения: продюсер записывает в него order_uid.
+		log := c.messageLogger(m, string(m.Key))
+		log.Warn("не удалось разобрать сообщение", "error", err, "content_type", contentType, "value", string(m.Value))
+		metrics.ConsumerMessages.WithLabelValues(metrics.OutcomeParseError).Inc()
+		return nil, log, c.deadLetter(ctx, log, m, StageParse, err)
+	}
+
+	order.SourceAt = m.Time
+	log := c.messageLogger(m, order.OrderUID)
+	log.Debug("заказ разобран", "content_type", contentType, "schema_version", version)
+
+	if err := c.ingest.Validate(order); err != nil {
+		stage := StageValidate
+		var rejected *ingest.RejectedError
+		if errors.As(err, &rejected) {
+			stage = rejected.Stage
+		}
+		if stage == StageRules {
+			log.Warn("заказ нарушает бизнес-правила", "error", err)
+			metrics.ConsumerMessages.WithLabelValues(metrics.OutcomeRuleViolation).Inc()
+		} else {
+			log.Warn("невалидные данные в заказе", "error", err)
+			metrics.ConsumerMessages.WithLabelValues(metrics.OutcomeValidationError).Inc()
+		}
+		return nil, log, c.deadLetter(ctx, log, m, stage, err)
+	}
+
+	return order, log, nil
+}
+
+// saveFailed отправляет в dead-letter topic заказ, который не удалось сохранить.
+func (c *Consumer) saveFailed(ctx context.Context, log *slog.Logger, m kafka.Message, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	outcome := metrics.OutcomeDBError
+	if errors.Is(err, database.ErrConflict) {
+		// Заказ изменён через API, и новое содержимое из Kafka его не перезаписывает.
+		outcome = metrics.OutcomeConflict
+	}
+	log.Error("не удалось сохранить заказ", "error", err, "attempts", c.retry.attempts())
+	metrics.ConsumerMessages.WithLabelValues(outcome).Inc()
+	return c.deadLetter(ctx, log, m, StageSave, err)
+}
+
+// saved учитывает результат сохранения; кэш уже обновлён сервисом приёма.
+func (c *Consumer) saved(log *slog.Logger, order *model.Order, result database.SaveResult) {
+	switch result {
+	case database.SaveUnchanged:
+		log.Info("заказ уже сохранен, повторное сообщение пропущено")
+		metrics.ConsumerMessages.WithLabelValues(metrics.OutcomeDuplicate).Inc()
+		return
+	case database.SaveStale:
+		log.Warn("сообщение старше сохранённого заказа, пропущено", "source_at", order.SourceAt)
+		metrics.ConsumerMessages.WithLabelValues(metrics.OutcomeStale).Inc()
+		return
+	case database.SaveUpdated:
+		log.Info("заказ обновлен в базе данных")
+		metrics.ConsumerMessages.WithLabelValues(metrics.OutcomeUpdated).Inc()
+	default:
+		log.Info("заказ сохранен в базу данных")
+		metrics.ConsumerMessages.WithLabelValues(metrics.OutcomeSaved).Inc()
+	}
+}
+
+// withRetry выполняет операцию с базой данных, повторяя попытки с экспоненциальной
+// задержкой. Ошибки, которые не исправятся повтором (см. permanent), возвращаются сразу.
+func (c *Consumer) withRetry(ctx context.Context, log *slog.Logger, op func() error) error {
+	var err error
+	for attempt := 1; attempt <= c.retry.attempts(); attempt++ {
+		if err = op(); err == nil || permanent(err) {
+			return err
+		}
+		if attempt == c.retry.attempts() {
+			break
+		}
+		delay := c.retry.Backoff(attempt)
+		log.Warn("ошибка обращения к базе данных, повтор",
+			"error", err, "attempt", attempt, "max_attempts", c.retry.attempts(), "delay", delay)
+		if sleepErr := sleep(ctx, delay); sleepErr != nil {
+			return sleepErr
+		}
+	}
+	return err
+}
+
+// deadLetter перекладывает сообщение в dead-letter topic. Публикация повторяется,
+// пока не завершится успешно или пока не будет отменён контекст: сообщение, которое
+// не удалось ни сохранить, ни отправить в dead-letter topic, подтверждать нельзя.
+func (c *Consumer) deadLetter(ctx context.Context, log *slog.Logger, m kafka.Message, stage string, cause error) error {
+	for attempt := 1; ; attempt++ {
+		err := c.dlq.Publish(ctx, m, stage, cause)
+		if err == nil {
+			break
+		}
+		log.Error("не удалось отправить сообщение в dead-letter topic", "error", err, "stage", stage, "attempt", attempt)
+		if sleepErr := sleep(ctx, c.retry.Backoff(attempt)); sleepErr != nil {
+			return sleepErr
 		}
 	}
+	log.Info("сообщение отправлено в dead-letter topic", "stage", stage)
+	return nil
 }
@@ -0,0 +1,101 @@
+package kafka
+
+import (
+	"L0_project/internal/ingest"
+	"L0_project/internal/rules"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Этапы обработки, на которых сообщение может быть отклонено.
+const (
+	StageParse    = ingest.StageParse
+	StageValidate = ingest.StageValidate
+	StageRules    = ingest.StageRules
+	StageSave     = "save"
+	// StageStatus — недопустимый переход статуса или событие для неизвестного заказа.
+	StageStatus = "status"
+)
+
+// Заголовки, которые добавляются к сообщению при отправке в dead-letter topic.
+const (
+	HeaderDLQStage     = "x-dlq-stage"
+	HeaderDLQError     = "x-dlq-error"
+	HeaderDLQTopic     = "x-dlq-source-topic"
+	HeaderDLQPartition = "x-dlq-source-partition"
+	HeaderDLQOffset    = "x-dlq-source-offset"
+	HeaderDLQTimestamp = "x-dlq-timestamp"
+	// HeaderDLQViolations — JSON-список нарушений бизнес-правил для этапа StageRules.
+	HeaderDLQViolations = "x-dlq-violations"
+)
+
+// writerBatchTimeout — сколько kafka.Writer ждёт добора пакета. Сообщения
+// публикуются по одному и синхронно, поэтому ожидание по умолчанию (1 с)
+// задерживало бы обработку на каждом сообщении.
+const writerBatchTimeout = 5 * time.Millisecond
+
+// DeadLetterWriter публикует отклонённые сообщения в отдельный topic,
+// сохраняя исходные ключ, тело и заголовки.
+type DeadLetterWriter struct {
+	writer *kafka.Writer
+}
+
+// NewDeadLetterWriter создает writer для dead-letter topic.
+func NewDeadLetterWriter(brokers []string, topic string) *DeadLetterWriter {
+	w := &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: writerBatchTimeout,
+	}
+	return &DeadLetterWriter{writer: w}
+}
+
+// Publish отправляет исходное сообщение в dead-letter topic с описанием причины отказа.
+func (d *DeadLetterWriter) Publish(ctx context.Context, m kafka.Message, stage string, cause error) error {
+	headers := make([]kafka.Header, 0, len(m.Headers)+7)
+	headers = append(headers, m.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: HeaderDLQStage, Value: []byte(stage)},
+		kafka.Header{Key: HeaderDLQError, Value: []byte(errorText(cause))},
+		kafka.Header{Key: HeaderDLQTopic, Value: []byte(m.Topic)},
+		kafka.Header{Key: HeaderDLQPartition, Value: []byte(strconv.Itoa(m.Partition))},
+		kafka.Header{Key: HeaderDLQOffset, Value: []byte(strconv.FormatInt(m.Offset, 10))},
+		kafka.Header{Key: HeaderDLQTimestamp, Value: []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+	)
+	var verr *rules.ViolationError
+	if errors.As(cause, &verr) {
+		if b, err := json.Marshal(verr.Violations); err == nil {
+			headers = append(headers, kafka.Header{Key: HeaderDLQViolations, Value: b})
+		}
+	}
+
+	err := d.writer.WriteMessages(ctx, kafka.Message{
+		Key:     m.Key,
+		Value:   m.Value,
+		Headers: headers,
+	})
+	if err != nil {
+		return fmt.Errorf("не удалось отправить сообщение в dead-letter topic %s: %w", d.writer.Topic, err)
+	}
+	return nil
+}
+
+// Close закрывает writer.
+func (d *DeadLetterWriter) Close() error {
+	return d.writer.Close()
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,50 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// readiness накапливает статистику reader: kafka-go обнуляет счётчики при каждом вызове Stats.
+type readiness struct {
+	mu        sync.Mutex
+	joined    bool
+	lastFetch time.Time
+}
+
+func (r *readiness) fetched() {
+	r.mu.Lock()
+	r.lastFetch = time.Now()
+	r.mu.Unlock()
+}
+
+// Ready проверяет, что consumer вступил в группу и недавно обращался к брокеру.
+// Reader выполняет fetch-запросы и на пустом topic (не реже раза в MaxWait),
+// поэтому отсутствие запросов дольше staleness означает потерю связи с Kafka.
+func (c *Consumer) Ready(ctx context.Context) error {
+	stats := c.reader.Stats()
+
+	c.ready.mu.Lock()
+	defer c.ready.mu.Unlock()
+
+	if stats.Rebalances > 0 {
+		c.ready.joined = true
+	}
+	if stats.Fetches > 0 {
+		c.ready.lastFetch = time.Now()
+	}
+
+	if !c.ready.joined {
+		return errors.New("consumer ещё не вступил в группу")
+	}
+	if c.ready.lastFetch.IsZero() {
+		return errors.New("consumer ещё не получал данные из Kafka")
+	}
+	if since := time.Since(c.ready.lastFetch); since > c.staleness {
+		return fmt.Errorf("последний запрос к Kafka был %s назад", since.Truncate(time.Second))
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package kafka
+
+import (
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// offsetTracker следит за сообщениями, которые обрабатываются параллельно, и
+// определяет, до какого offset партицию можно подтвердить: подтверждение покрывает
+// только непрерывный префикс завершённых сообщений, поэтому сообщение, которое ещё
+// обрабатывается, не будет пропущено после перезапуска.
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[int]*partitionOffsets
+	// commit — последнее сообщение непрерывного префикса по партициям, ещё не подтверждённое.
+	commit map[int]kafka.Message
+	// ready сигнализирует, что в commit появились новые сообщения.
+	ready chan struct{}
+}
+
+type partitionOffsets struct {
+	// inflight — сообщения партиции в порядке получения.
+	inflight []kafka.Message
+	done     map[int64]bool
+	// last — offset последнего полученного сообщения партиции.
+	last int64
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{
+		partitions: make(map[int]*partitionOffsets),
+		commit:     make(map[int]kafka.Message),
+		ready:      make(chan struct{}, 1),
+	}
+}
+
+// add регистрирует полученное сообщение. Вызывается в порядке чтения из партиции.
+// Сообщение с offset не больше уже полученного — повторная доставка после
+// перебалансировки, пока первое ещё обрабатывается или ждёт подтверждения; такое
+// сообщение не регистрируется, и add возвращает false.
+func (t *offsetTracker) add(m kafka.Message) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.partitions[m.Partition]
+	if !ok {
+		p = &partitionOffsets{done: make(map[int64]bool), last: -1}
+		t.partitions[m.Partition] = p
+	}
+	if m.Offset <= p.last {
+		return false
+	}
+	p.last = m.Offset
+	p.inflight = append(p.inflight, m)
+	return true
+}
+
+// complete отмечает сообщения обработанными и продвигает точку подтверждения
+// их партиций через непрерывный префикс завершённых сообщений.
+func (t *offsetTracker) complete(msgs ...kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	advanced := false
+	for _, m := range msgs {
+		p := t.partitions[m.Partition]
+		// Сообщения до начала inflight уже подтверждаются; повторное завершение не
+		// должно оставлять их в done навсегда.
+		if p == nil || len(p.inflight) == 0 || m.Offset < p.inflight[0].Offset {
+			continue
+		}
+		p.done[m.Offset] = true
+		for len(p.inflight) > 0 && p.done[p.inflight[0].Offset] {
+			head := p.inflight[0]
+			delete(p.done, head.Offset)
+			p.inflight = p.inflight[1:]
+			t.commit[m.Partition] = head
+			advanced = true
+		}
+	}
+	if advanced {
+		select {
+		case t.ready <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// take возвращает сообщения, которые можно подтвердить, по одному на партицию.
+func (t *offsetTracker) take() []kafka.Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	msgs := make([]kafka.Message, 0, len(t.commit))
+	for partition, m := range t.commit {
+		msgs = append(msgs, m)
+		delete(t.commit, partition)
+	}
+	return msgs
+}
+
+// close завершает сигналы ready; после него commitLoop делает последнее подтверждение.
+func (t *offsetTracker) close() {
+	close(t.ready)
+}
@@ -0,0 +1,138 @@
+package kafka
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func msg(partition int, offset int64) kafka.Message {
+	return kafka.Message{Partition: partition, Offset: offset}
+}
+
+func msgs(partition int, offsets ...int64) []kafka.Message {
+	res := make([]kafka.Message, len(offsets))
+	for i, offset := range offsets {
+		res[i] = msg(partition, offset)
+	}
+	return res
+}
+
+func TestOffsetTracker(t *testing.T) {
+	// step — шаг сценария: add регистрирует новые сообщения, dup — повторные
+	// доставки, которые add должен отбросить, затем complete и take.
+	type step struct {
+		add, dup, complete []kafka.Message
+		// take — ожидаемый offset подтверждения по партициям.
+		take map[int]int64
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"по порядку", []step{
+			{add: msgs(0, 0, 1, 2), complete: msgs(0, 0, 1, 2), take: map[int]int64{0: 2}},
+		}},
+		{"не по порядку", []step{
+			{add: msgs(0, 0, 1, 2), complete: msgs(0, 2, 1), take: map[int]int64{}},
+			{complete: msgs(0, 0), take: map[int]int64{0: 2}},
+		}},
+		{"незавершённое сообщение держит префикс", []step{
+			{add: msgs(0, 0, 1, 2), complete: msgs(0, 0, 2), take: map[int]int64{0: 0}},
+			{complete: msgs(0, 1), take: map[int]int64{0: 2}},
+		}},
+		{"пропуски offset", []step{
+			{add: msgs(0, 5, 9, 20), complete: msgs(0, 9), take: map[int]int64{}},
+			{complete: msgs(0, 5), take: map[int]int64{0: 9}},
+			{complete: msgs(0, 20), take: map[int]int64{0: 20}},
+		}},
+		{"повторная доставка", []step{
+			{add: msgs(0, 0, 1), dup: msgs(0, 1, 0), complete: msgs(0, 1), take: map[int]int64{}},
+			{complete: msgs(0, 0), take: map[int]int64{0: 1}},
+			{add: msgs(0, 2), dup: msgs(0, 0, 2), complete: msgs(0, 2), take: map[int]int64{0: 2}},
+		}},
+		{"повторное завершение", []step{
+			{add: msgs(0, 0, 1), complete: msgs(0, 0, 0), take: map[int]int64{0: 0}},
+			{complete: msgs(0, 0, 1, 1), take: map[int]int64{0: 1}},
+		}},
+		{"завершение неизвестного сообщения", []step{
+			{add: msgs(0, 3), complete: append(msgs(1, 0), msg(0, 1)), take: map[int]int64{}},
+			{complete: msgs(0, 3), take: map[int]int64{0: 3}},
+		}},
+		{"партиции независимы", []step{
+			{add: append(msgs(0, 0, 1), msgs(1, 10, 11)...), complete: append(msgs(1, 10), msg(0, 1)), take: map[int]int64{1: 10}},
+			{complete: msgs(0, 0), take: map[int]int64{0: 1}},
+			{complete: msgs(1, 11), take: map[int]int64{1: 11}},
+		}},
+		{"take после переназначения партиций", []step{
+			{add: append(msgs(0, 0, 1, 2), msgs(1, 0, 1)...), complete: msgs(0, 0, 1, 2), take: map[int]int64{0: 2}},
+			// После перебалансировки партиция 0 читается с подтверждённого offset 3,
+			// а партиция 1, ещё не подтверждённая, — заново с начала.
+			{add: msgs(0, 3), dup: msgs(1, 0, 1), complete: append(msgs(1, 0, 1), msg(0, 3)), take: map[int]int64{0: 3, 1: 1}},
+			{take: map[int]int64{}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := newOffsetTracker()
+			for i, s := range tt.steps {
+				for _, m := range s.add {
+					if !tr.add(m) {
+						t.Fatalf("шаг %d: add(%d/%d) отбросил новое сообщение", i, m.Partition, m.Offset)
+					}
+				}
+				for _, m := range s.dup {
+					if tr.add(m) {
+						t.Fatalf("шаг %d: add(%d/%d) принял повторную доставку", i, m.Partition, m.Offset)
+					}
+				}
+				tr.complete(s.complete...)
+
+				got := make(map[int]int64)
+				for _, m := range tr.take() {
+					if _, ok := got[m.Partition]; ok {
+						t.Fatalf("шаг %d: take вернул партицию %d дважды", i, m.Partition)
+					}
+					got[m.Partition] = m.Offset
+				}
+				if !maps.Equal(got, s.take) {
+					t.Fatalf("шаг %d: take = %v, ожидалось %v", i, got, s.take)
+				}
+			}
+		})
+	}
+}
+
+func TestOffsetTrackerForgetsCompleted(t *testing.T) {
+	tr := newOffsetTracker()
+	for _, m := range msgs(0, 0, 1, 2) {
+		tr.add(m)
+	}
+	tr.complete(msgs(0, 2, 1, 0, 1, 2)...)
+
+	p := tr.partitions[0]
+	if len(p.inflight) != 0 || len(p.done) != 0 {
+		t.Errorf("после завершения всех сообщений inflight = %d, done = %d", len(p.inflight), len(p.done))
+	}
+}
+
+func TestOffsetTrackerReady(t *testing.T) {
+	tr := newOffsetTracker()
+	tr.add(msg(0, 0))
+	tr.add(msg(0, 1))
+
+	tr.complete(msg(0, 1))
+	select {
+	case <-tr.ready:
+		t.Fatal("ready без продвижения точки подтверждения")
+	default:
+	}
+
+	tr.complete(msg(0, 0))
+	select {
+	case <-tr.ready:
+	default:
+		t.Fatal("нет сигнала ready после продвижения точки подтверждения")
+	}
+}
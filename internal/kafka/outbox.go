@@ -0,0 +1,132 @@
+package kafka
+
+import (
+	"L0_project/internal/database"
+	"L0_project/internal/metrics"
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// HeaderEventID — идентификатор события outbox; по нему получатели отбрасывают повторы.
+const HeaderEventID = "x-event-id"
+
+// OutboxRelayConfig задаёт параметры публикации событий из outbox.
+type OutboxRelayConfig struct {
+	Brokers []string
+	Topic   string
+	// BatchSize — сколько событий публиковать за один проход. По умолчанию 100.
+	BatchSize int
+	// PollInterval — пауза между проходами, когда неопубликованных событий нет.
+	// По умолчанию одна секунда.
+	PollInterval time.Duration
+	// Retry задаёт задержку после неудачной публикации. Без MaxBackoff задержка
+	// ограничена одной минутой: проходы повторяются, пока Kafka не станет доступна.
+	Retry RetryPolicy
+}
+
+// OutboxRelay публикует события из таблицы outbox в Kafka. Событие отмечается
+// опубликованным только после подтверждения всеми репликами брокера.
+type OutboxRelay struct {
+	writer   *kafka.Writer
+	db       database.OutboxStorage
+	batch    int
+	interval time.Duration
+	retry    RetryPolicy
+	log      *slog.Logger
+}
+
+// NewOutboxRelay создает relay для topic из cfg.
+func NewOutboxRelay(cfg OutboxRelayConfig, db database.OutboxStorage, log *slog.Logger) *OutboxRelay {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.Retry.MaxBackoff <= 0 {
+		cfg.Retry.MaxBackoff = time.Minute
+	}
+	return &OutboxRelay{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(cfg.Brokers...),
+			Topic:        cfg.Topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			// События публикуются внутри транзакции с FOR UPDATE SKIP LOCKED:
+			// ожидание добора пакета держало бы её открытой.
+			BatchTimeout: writerBatchTimeout,
+		},
+		db:       db,
+		batch:    cfg.BatchSize,
+		interval: cfg.PollInterval,
+		retry:    cfg.Retry,
+		log:      log.With("component", "outbox_relay", "topic", cfg.Topic),
+	}
+}
+
+// Run публикует события до отмены контекста. Пока в outbox есть события, проходы
+// идут без паузы; после ошибки задержка растёт экспоненциально.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	r.log.Info("relay outbox запущен")
+	defer func() {
+		if err := r.writer.Close(); err != nil {
+			r.log.Error("ошибка при закрытии писателя Kafka", "error", err)
+		}
+		r.log.Info("relay outbox остановлен")
+	}()
+
+	failures := 0
+	for {
+		n, err := r.db.RelayOutbox(ctx, r.batch, r.publish)
+		if ctx.Err() != nil {
+			return
+		}
+
+		delay := r.interval
+		switch {
+		case err != nil:
+			failures++
+			delay = r.retry.Backoff(failures)
+			r.log.Error("не удалось опубликовать события outbox", "error", err, "attempt", failures, "retry_in", delay)
+			metrics.OutboxEvents.WithLabelValues(metrics.OutboxFailed).Inc()
+		case n > 0:
+			failures = 0
+			r.log.Debug("события outbox опубликованы", "count", n)
+			metrics.OutboxEvents.WithLabelValues(metrics.OutboxPublished).Add(float64(n))
+			if n == r.batch {
+				delay = 0
+			}
+		default:
+			failures = 0
+		}
+
+		if sleep(ctx, delay) != nil {
+			return
+		}
+	}
+}
+
+// publish отправляет события синхронно; ключ сообщения — идентификатор заказа,
+// поэтому события одного заказа попадают в одну партицию по порядку.
+func (r *OutboxRelay) publish(ctx context.Context, events []database.OutboxEvent) error {
+	msgs := make([]kafka.Message, len(events))
+	for i, e := range events {
+		msgs[i] = kafka.Message{
+			Key:   []byte(e.AggregateID),
+			Value: e.Payload,
+			Headers: []kafka.Header{
+				{Key: HeaderEventType, Value: []byte(e.EventType)},
+				{Key: HeaderEventID, Value: []byte(strconv.FormatInt(e.ID, 10))},
+			},
+		}
+	}
+	if err := r.writer.WriteMessages(ctx, msgs...); err != nil {
+		return fmt.Errorf("не удалось отправить события в topic %s: %w", r.writer.Topic, err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package kafka
+
+import (
+	"context"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// commitTimeout ограничивает одно подтверждение offset в параллельном режиме.
+const commitTimeout = 10 * time.Second
+
+// runParallel распределяет сообщения между workers обработчиками по хэшу ключа
+// (order_uid), поэтому события одного заказа обрабатываются по порядку одним
+// обработчиком, а разные заказы — параллельно. Offset подтверждаются отдельной
+// горутиной через offsetTracker.
+//
+// После отмены ctx новые сообщения не читаются, а уже полученные дообрабатываются
+// не дольше drainTimeout; затем подтверждается достигнутый offset.
+func (c *Consumer) runParallel(ctx context.Context) {
+	// Обработка не прерывается отменой ctx, чтобы полученные сообщения можно было дообработать.
+	workCtx, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWork()
+
+	tracker := newOffsetTracker()
+	committed := make(chan struct{})
+	go func() {
+		defer close(committed)
+		c.commitLoop(tracker)
+	}()
+
+	queues := make([]chan kafka.Message, c.workers)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan kafka.Message, max(c.batchSize, 1))
+		wg.Add(1)
+		go func(in <-chan kafka.Message) {
+			defer wg.Done()
+			c.worker(workCtx, in, tracker)
+		}(queues[i])
+	}
+
+dispatch:
+	for {
+		m, err := c.fetch(ctx)
+		if err != nil {
+			break
+		}
+		if !tracker.add(m) {
+			// Это сообщение уже обрабатывается: после перебалансировки партиция
+			// читается заново с последнего подтверждённого offset.
+			c.log.Debug("повторно полученное сообщение пропущено", "partition", m.Partition, "offset", m.Offset)
+			continue
+		}
+		select {
+		case queues[c.workerFor(m)] <- m:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+
+	for _, q := range queues {
+		close(q)
+	}
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+
+	c.log.Info("дообработка полученных сообщений", "timeout", c.drainTimeout)
+	select {
+	case <-drained:
+	case <-time.After(c.drainTimeout):
+		c.log.Warn("дообработка не завершилась вовремя, прерываем")
+		cancelWork()
+		<-drained
+	}
+
+	tracker.close()
+	<-committed
+}
+
+// workerFor выбирает обработчик по ключу сообщения; сообщения без ключа
+// распределяются по партиции.
+func (c *Consumer) workerFor(m kafka.Message) int {
+	h := fnv.New32a()
+	if len(m.Key) > 0 {
+		h.Write(m.Key)
+	} else {
+		h.Write([]byte(strconv.Itoa(m.Partition)))
+	}
+	return int(h.Sum32() % uint32(c.workers))
+}
+
+// worker обрабатывает сообщения своей очереди. Если в очереди уже лежит несколько
+// сообщений и включён пакетный режим, они обрабатываются одним пакетом.
+func (c *Consumer) worker(ctx context.Context, in <-chan kafka.Message, tracker *offsetTracker) {
+	for m := range in {
+		batch := []kafka.Message{m}
+	collect:
+		for len(batch) < c.batchSize {
+			select {
+			case next, ok := <-in:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, next)
+			default:
+				break collect
+			}
+		}
+
+		var err error
+		if len(batch) == 1 {
+			err = c.handleMessage(ctx, m)
+		} else {
+			err = c.handleBatch(ctx, batch)
+		}
+		if err != nil {
+			// Дообработка прервана: сообщения не подтверждаются и будут прочитаны повторно.
+			return
+		}
+		tracker.complete(batch...)
+	}
+}
+
+// commitLoop подтверждает offset, продвинутые обработчиками. Подтверждения
+// выполняет одна горутина, поэтому offset партиции не откатывается назад.
+func (c *Consumer) commitLoop(tracker *offsetTracker) {
+	commit := func() {
+		msgs := tracker.take()
+		if len(msgs) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), commitTimeout)
+		defer cancel()
+		if err := c.reader.CommitMessages(ctx, msgs...); err != nil {
+			c.log.Error("не удалось подтвердить сообщения", "error", err)
+		}
+	}
+
+	for range tracker.ready {
+		commit()
+	}
+	commit()
+}
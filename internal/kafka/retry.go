@@ -0,0 +1,64 @@
+package kafka
+
+import (
+	"L0_project/internal/database"
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy описывает повторные попытки с экспоненциальной задержкой и jitter.
+type RetryPolicy struct {
+	// MaxAttempts — максимальное число попыток, включая первую.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// Backoff возвращает задержку перед попыткой с номером attempt+1.
+// Задержка растёт экспоненциально и ограничена MaxBackoff, если он задан; половина задержки случайна,
+// чтобы несколько экземпляров сервиса не обращались к базе одновременно.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	d := p.InitialBackoff
+	if d <= 0 {
+		d = 100 * time.Millisecond
+	}
+	// Без MaxBackoff рост ограничен только переполнением: счётчик попыток relay
+	// outbox не ограничен.
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff) && d <= math.MaxInt64/2; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+// attempts возвращает число попыток, не меньше одной.
+func (p RetryPolicy) attempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// permanent сообщает, что ошибка вызвана данными сообщения и повтор её не исправит:
+// заказа нет, операция противоречит его состоянию или Postgres отклонил данные.
+func permanent(err error) bool {
+	return errors.Is(err, database.ErrNotFound) || errors.Is(err, database.ErrConflict) || database.IsDataError(err)
+}
+
+// sleep ожидает d или отмены контекста.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,46 @@
+package kafka
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		attempt  int
+		min, max time.Duration
+	}{
+		{"первая попытка", RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}, 1, 50 * time.Millisecond, 100 * time.Millisecond},
+		{"рост", RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}, 3, 200 * time.Millisecond, 400 * time.Millisecond},
+		{"ограничение MaxBackoff", RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}, 10, 500 * time.Millisecond, time.Second},
+		{"без InitialBackoff", RetryPolicy{}, 1, 50 * time.Millisecond, 100 * time.Millisecond},
+		{"без MaxBackoff растёт", RetryPolicy{InitialBackoff: 100 * time.Millisecond}, 5, 800 * time.Millisecond, 1600 * time.Millisecond},
+		{"нулевая политика растёт", RetryPolicy{}, 4, 400 * time.Millisecond, 800 * time.Millisecond},
+		// При переполнении задержка стала бы отрицательной, а rand.N запаниковал бы.
+		{"без MaxBackoff без переполнения", RetryPolicy{InitialBackoff: time.Millisecond}, 1000, 100 * 365 * 24 * time.Hour, math.MaxInt64},
+		{"огромный MaxBackoff без переполнения", RetryPolicy{InitialBackoff: time.Millisecond, MaxBackoff: math.MaxInt64}, 1 << 20, 100 * 365 * 24 * time.Hour, math.MaxInt64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 100 {
+				d := tt.policy.Backoff(tt.attempt)
+				if d < tt.min || d > tt.max {
+					t.Fatalf("Backoff(%d) = %v, ожидалось от %v до %v", tt.attempt, d, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoffMonotonic(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 10 * time.Millisecond}
+	// Без случайной половины задержка попытки n+1 не меньше максимума попытки n.
+	for attempt := 1; attempt < 200; attempt++ {
+		if lo, hi := p.Backoff(attempt+1), p.Backoff(attempt); lo < hi/2 {
+			t.Fatalf("Backoff(%d) = %v меньше половины Backoff(%d) = %v", attempt+1, lo, attempt, hi)
+		}
+	}
+}
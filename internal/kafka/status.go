@@ -0,0 +1,124 @@
+package kafka
+
+import (
+	"L0_project/internal/metrics"
+	"L0_project/internal/model"
+	"L0_project/internal/schema"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// HeaderEventType задаёт тип события в сообщении. Сообщение без заголовка
+// считается полным заказом (EventOrder).
+const HeaderEventType = "x-event-type"
+
+// Типы событий в заголовке HeaderEventType.
+const (
+	EventOrder       = "order"
+	EventOrderStatus = "order_status"
+)
+
+// StatusEvent — событие смены статуса заказа. В отличие от EventOrder,
+// заказ целиком не передаётся.
+type StatusEvent struct {
+	OrderUID string            `json:"order_uid" validate:"required,uuid4"`
+	Status   model.OrderStatus `json:"status" validate:"required"`
+}
+
+// HeaderSchemaVersion — версия схемы заказа в сообщении EventOrder (см. пакет schema).
+// Сообщение без заголовка считается версией schema.V1.
+const HeaderSchemaVersion = "x-schema-version"
+
+// HeaderContentType — формат сообщения EventOrder (см. пакет codec).
+// Сообщение без заголовка считается JSON.
+const HeaderContentType = "content-type"
+
+// header возвращает значение заголовка сообщения.
+func header(m kafka.Message, key string) (string, bool) {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return string(h.Value), true
+		}
+	}
+	return "", false
+}
+
+// schemaVersion возвращает версию схемы из заголовков сообщения.
+func schemaVersion(m kafka.Message) (int, error) {
+	value, ok := header(m, HeaderSchemaVersion)
+	if !ok {
+		return schema.V1, nil
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", schema.ErrUnsupportedVersion, value)
+	}
+	return v, nil
+}
+
+// eventType возвращает тип события из заголовков сообщения.
+func eventType(m kafka.Message) string {
+	if value, ok := header(m, HeaderEventType); ok {
+		return value
+	}
+	return EventOrder
+}
+
+// handleStatusEvent применяет событие смены статуса. Недопустимый переход и событие
+// для неизвестного заказа не исправятся повтором и сразу уходят в dead-letter topic.
+func (c *Consumer) handleStatusEvent(ctx context.Context, m kafka.Message) error {
+	var event StatusEvent
+	if err := json.Unmarshal(m.Value, &event); err != nil {
+		log := c.messageLogger(m, string(m.Key))
+		log.Warn("не удалось разобрать событие статуса", "error", err, "value", string(m.Value))
+		metrics.ConsumerMessages.WithLabelValues(metrics.OutcomeParseError).Inc()
+		return c.deadLetter(ctx, log, m, StageParse, err)
+	}
+
+	log := c.messageLogger(m, event.OrderUID).With("status", event.Status)
+
+	err := c.validate.Struct(&event)
+	if err == nil && !event.Status.Valid() {
+		err = fmt.Errorf("неизвестный статус заказа %q", event.Status)
+	}
+	if err != nil {
+		log.Warn("невалидное событие статуса", "error", err)
+		metrics.ConsumerMessages.WithLabelValues(metrics.OutcomeValidationError).Inc()
+		return c.deadLetter(ctx, log, m, StageValidate, err)
+	}
+
+	var changed bool
+	err = c.withRetry(ctx, log, func() error {
+		var err error
+		changed, err = c.db.UpdateStatus(ctx, event.OrderUID, event.Status, model.StatusSourceKafka)
+		return err
+	})
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case permanent(err):
+		log.Warn("статус заказа не изменён", "error", err)
+		metrics.ConsumerMessages.WithLabelValues(metrics.OutcomeStatusRejected).Inc()
+		return c.deadLetter(ctx, log, m, StageStatus, err)
+	default:
+		log.Error("не удалось изменить статус заказа, попытки исчерпаны", "error", err, "attempts", c.retry.attempts())
+		metrics.ConsumerMessages.WithLabelValues(metrics.OutcomeDBError).Inc()
+		return c.deadLetter(ctx, log, m, StageSave, err)
+	}
+
+	if !changed {
+		log.Info("заказ уже в этом статусе, повторное событие пропущено")
+		metrics.ConsumerMessages.WithLabelValues(metrics.OutcomeDuplicate).Inc()
+		return nil
+	}
+
+	log.Info("статус заказа изменён")
+	metrics.ConsumerMessages.WithLabelValues(metrics.OutcomeStatusChanged).Inc()
+	c.cache.Remove(event.OrderUID)
+	return nil
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"L0_project/internal/cache"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// cacheCollector публикует статистику OrderCache на момент сбора метрик.
+type cacheCollector struct {
+	cache       cache.OrderCache
+	hits        *prometheus.Desc
+	misses      *prometheus.Desc
+	evictions   *prometheus.Desc
+	expirations *prometheus.Desc
+	size        *prometheus.Desc
+	bytes       *prometheus.Desc
+}
+
+// RegisterCache регистрирует метрики кэша заказов.
+func RegisterCache(c cache.OrderCache) {
+	prometheus.MustRegister(&cacheCollector{
+		cache:       c,
+		hits:        prometheus.NewDesc(namespace+"_cache_hits_total", "Количество попаданий в кэш заказов.", nil, nil),
+		misses:      prometheus.NewDesc(namespace+"_cache_misses_total", "Количество промахов кэша заказов.", nil, nil),
+		evictions:   prometheus.NewDesc(namespace+"_cache_evictions_total", "Количество заказов, вытесненных из кэша по числу записей или бюджету памяти.", nil, nil),
+		expirations: prometheus.NewDesc(namespace+"_cache_expirations_total", "Количество заказов, удалённых из кэша по истечении TTL.", nil, nil),
+		size:        prometheus.NewDesc(namespace+"_cache_size", "Текущее количество заказов в кэше.", nil, nil),
+		bytes:       prometheus.NewDesc(namespace+"_cache_bytes", "Оценка памяти, занятой заказами в кэше, в байтах.", nil, nil),
+	})
+}
+
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.evictions
+	ch <- c.expirations
+	ch <- c.size
+	ch <- c.bytes
+}
+
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.cache.Stats()
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(s.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(s.Misses))
+	ch <- prometheus.MustNewConstMetric(c.evictions, prometheus.CounterValue, float64(s.Evictions))
+	ch <- prometheus.MustNewConstMetric(c.expirations, prometheus.CounterValue, float64(s.Expirations))
+	ch <- prometheus.MustNewConstMetric(c.size, prometheus.GaugeValue, float64(s.Size))
+	ch <- prometheus.MustNewConstMetric(c.bytes, prometheus.GaugeValue, float64(s.Bytes))
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "orders"
+
+// Результаты обработки сообщения consumer (значения метки outcome).
+const (
+	OutcomeSaved           = "saved"
+	OutcomeUpdated         = "updated"
+	OutcomeDuplicate       = "duplicate"
+	OutcomeStale           = "stale"
+	OutcomeParseError      = "parse_error"
+	OutcomeValidationError = "validation_error"
+	OutcomeRuleViolation   = "rule_violation"
+	OutcomeDBError         = "db_error"
+	OutcomeConflict        = "conflict"
+	OutcomeStatusChanged   = "status_changed"
+	OutcomeStatusRejected  = "status_rejected"
+)
+
+// Результаты публикации событий outbox (значения метки outcome).
+const (
+	OutboxPublished = "published"
+	OutboxFailed    = "failed"
+)
+
+var (
+	// ConsumerMessages считает обработанные сообщения Kafka по результату.
+	ConsumerMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "messages_total",
+		Help:      "Количество обработанных сообщений Kafka по результату обработки.",
+	}, []string{"outcome"})
+
+	// ConsumerLag — отставание consumer от конца партиции в сообщениях.
+	ConsumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "lag",
+		Help:      "Отставание consumer от high watermark партиции в сообщениях.",
+	}, []string{"partition"})
+
+	// ConsumerBatchSize — число сообщений в пакете при пакетной обработке.
+	ConsumerBatchSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "batch_size",
+		Help:      "Число сообщений Kafka в одном обработанном пакете.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+	})
+
+	// OutboxEvents считает опубликованные события outbox и неудачные попытки публикации.
+	OutboxEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "outbox",
+		Name:      "events_total",
+		Help:      "Опубликованные события outbox (published) и неудачные проходы публикации (failed).",
+	}, []string{"outcome"})
+
+	// DBQueryDuration — длительность методов Storage.
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Длительность вызовов методов Storage в секундах.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "status"})
+
+	// HTTPRequestDuration — длительность HTTP-запросов по маршруту chi и статусу ответа.
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Длительность HTTP-запросов в секундах по маршруту и статусу ответа.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+// ObserveDBQuery записывает длительность вызова метода Storage, начатого в start.
+// Используется как defer metrics.ObserveDBQuery("Method", time.Now(), &err).
+func ObserveDBQuery(method string, start time.Time, err *error) {
+	status := "ok"
+	switch {
+	case err == nil || *err == nil:
+	case errors.Is(*err, sql.ErrNoRows):
+		status = "not_found"
+	default:
+		status = "error"
+	}
+	DBQueryDuration.WithLabelValues(method, status).Observe(time.Since(start).Seconds())
+}
+
+// Handler возвращает HTTP-обработчик для эндпоинта /metrics.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
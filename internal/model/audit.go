@@ -0,0 +1,28 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Действия, записываемые в журнал изменений заказа.
+const (
+	AuditAmendDelivery = "amend_delivery"
+	AuditAmendItem     = "amend_item"
+	AuditRemoveItem    = "remove_item"
+	AuditCancel        = "cancel"
+)
+
+// AuditEntry — запись журнала изменений заказа. Before и After содержат только
+// изменившиеся поля заказа в том же виде, что и JSON заказа.
+type AuditEntry struct {
+	OrderUID string `json:"-"`
+	// Version — версия заказа после изменения.
+	Version   int             `json:"version"`
+	Action    string          `json:"action"`
+	Actor     string          `json:"actor"`
+	Reason    string          `json:"reason,omitempty"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	ChangedAt time.Time       `json:"changed_at"`
+}
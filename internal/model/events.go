@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// EventOrderAccepted — тип события о принятии нового заказа.
+const EventOrderAccepted = "OrderAccepted"
+
+// OrderAccepted публикуется для внешних систем (биллинг, уведомления), когда
+// заказ впервые сохранён. Содержит сводку заказа, а не заказ целиком.
+type OrderAccepted struct {
+	OrderUID    string    `json:"order_uid"`
+	TrackNumber string    `json:"track_number"`
+	CustomerID  string    `json:"customer_id"`
+	Amount      int       `json:"amount"`
+	Currency    string    `json:"currency"`
+	ItemsCount  int       `json:"items_count"`
+	DateCreated time.Time `json:"date_created"`
+	AcceptedAt  time.Time `json:"accepted_at"`
+}
+
+// NewOrderAccepted собирает событие OrderAccepted по заказу.
+func NewOrderAccepted(order *Order, acceptedAt time.Time) OrderAccepted {
+	return OrderAccepted{
+		OrderUID:    order.OrderUID,
+		TrackNumber: order.TrackNumber,
+		CustomerID:  order.CustomerID,
+		Amount:      order.Payment.Amount,
+		Currency:    order.Payment.Currency,
+		ItemsCount:  len(order.Items),
+		DateCreated: order.DateCreated,
+		AcceptedAt:  acceptedAt,
+	}
+}
@@ -19,6 +19,16 @@ type Order struct {
 	SmID              int       `json:"sm_id" db:"sm_id"`
 	DateCreated       time.Time `json:"date_created" db:"date_created" validate:"required"`
 	OofShard          string    `json:"oof_shard" db:"oof_shard"`
+	// Status ведётся сервисом и меняется только через переходы статуса;
+	// значение из присланного заказа игнорируется.
+	Status OrderStatus `json:"status,omitempty" db:"status"`
+	// Version тоже ведётся сервисом: растёт при каждом изменении заказа
+	// и отдаётся в API как ETag.
+	Version int `json:"version,omitempty" db:"version"`
+	// SourceAt — время сообщения Kafka, из которого получен заказ; нулевое для
+	// заказов из API. По нему запоздавшая повторная доставка отличается от нового
+	// содержимого. В JSON и хэш содержимого не попадает.
+	SourceAt time.Time `json:"-" db:"-"`
 }
 
 type Delivery struct {
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// OrderStatus — статус заказа в его жизненном цикле.
+type OrderStatus string
+
+const (
+	StatusCreated    OrderStatus = "created"
+	StatusPaid       OrderStatus = "paid"
+	StatusAssembling OrderStatus = "assembling"
+	StatusShipped    OrderStatus = "shipped"
+	StatusDelivered  OrderStatus = "delivered"
+	StatusCancelled  OrderStatus = "cancelled"
+	StatusReturned   OrderStatus = "returned"
+)
+
+// ErrInvalidTransition возвращается при попытке недопустимого перехода между статусами.
+var ErrInvalidTransition = errors.New("недопустимый переход статуса заказа")
+
+// transitions — допустимые переходы конечного автомата статусов.
+// Отменить можно только заказ, который ещё не передан в доставку;
+// вернуть — только доставленный. cancelled и returned — конечные статусы.
+var transitions = map[OrderStatus][]OrderStatus{
+	StatusCreated:    {StatusPaid, StatusCancelled},
+	StatusPaid:       {StatusAssembling, StatusCancelled},
+	StatusAssembling: {StatusShipped, StatusCancelled},
+	StatusShipped:    {StatusDelivered},
+	StatusDelivered:  {StatusReturned},
+	StatusCancelled:  nil,
+	StatusReturned:   nil,
+}
+
+// ErrNotAmendable возвращается при попытке изменить заказ в статусе, в котором это уже нельзя.
+var ErrNotAmendable = errors.New("заказ в текущем статусе нельзя изменить")
+
+// Valid сообщает, является ли s известным статусом.
+func (s OrderStatus) Valid() bool {
+	_, ok := transitions[s]
+	return ok
+}
+
+// CanTransition сообщает, допустим ли переход из s в to.
+func (s OrderStatus) CanTransition(to OrderStatus) bool {
+	for _, next := range transitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAmendDelivery сообщает, можно ли изменить доставку: только пока заказ не передан в доставку.
+func (s OrderStatus) CanAmendDelivery() bool {
+	return s == StatusCreated || s == StatusPaid || s == StatusAssembling
+}
+
+// CanAmendItems сообщает, можно ли изменить состав заказа: только до оплаты.
+func (s OrderStatus) CanAmendItems() bool {
+	return s == StatusCreated
+}
+
+// Transition проверяет переход из s в to и возвращает ошибку, оборачивающую
+// ErrInvalidTransition, если он недопустим.
+func (s OrderStatus) Transition(to OrderStatus) error {
+	if !s.CanTransition(to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, s, to)
+	}
+	return nil
+}
+
+// Источники изменения статуса, записываемые в историю.
+const (
+	StatusSourceKafka = "kafka"
+	StatusSourceAPI   = "api"
+)
+
+// StatusChange — запись истории статусов заказа.
+type StatusChange struct {
+	OrderUID string `json:"-" db:"order_uid"`
+	// From пуст для первой записи, созданной вместе с заказом.
+	From      OrderStatus `json:"from,omitempty" db:"from_status"`
+	To        OrderStatus `json:"to" db:"to_status"`
+	Source    string      `json:"source" db:"source"`
+	ChangedAt time.Time   `json:"changed_at" db:"changed_at"`
+}
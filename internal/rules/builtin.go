@@ -0,0 +1,150 @@
+package rules
+
+import (
+	"L0_project/internal/model"
+	"fmt"
+	"time"
+)
+
+// Имена встроенных правил; используются в RULES_DISABLED, RULES_ENABLED и в нарушениях.
+const (
+	RuleGoodsTotal         = "goods_total"
+	RuleItemTotalPrice     = "item_total_price"
+	RuleItemTrackNumber    = "item_track_number"
+	RulePaymentTransaction = "payment_transaction"
+	RulePaymentAmount      = "payment_amount"
+	RuleCurrency           = "currency"
+	RuleDateCreated        = "date_created"
+)
+
+// Builtin возвращает встроенные правила, включённые по умолчанию. maxClockSkew —
+// допустимое опережение date_created, чтобы расхождение часов продюсера не отклоняло заказы.
+func Builtin(maxClockSkew time.Duration) []Rule {
+	return []Rule{
+		{Name: RuleGoodsTotal, Check: checkGoodsTotal},
+		{Name: RuleItemTotalPrice, Check: checkItemTotalPrice},
+		{Name: RuleItemTrackNumber, Check: checkItemTrackNumber},
+		{Name: RulePaymentTransaction, Check: checkPaymentTransaction},
+		{Name: RuleCurrency, Check: checkCurrency},
+		{Name: RuleDateCreated, Check: dateNotInFuture(time.Now, maxClockSkew)},
+	}
+}
+
+// Optional возвращает встроенные правила, которые включаются только явно
+// через Config.Enabled: не все продюсеры заполняют payment.amount по этой формуле.
+func Optional() []Rule {
+	return []Rule{
+		{Name: RulePaymentAmount, Check: checkPaymentAmount},
+	}
+}
+
+// checkGoodsTotal: payment.goods_total равен сумме items[].total_price.
+func checkGoodsTotal(order *model.Order) []Violation {
+	sum := 0
+	for _, item := range order.Items {
+		sum += item.TotalPrice
+	}
+	if order.Payment.GoodsTotal != sum {
+		return []Violation{{
+			Rule:    RuleGoodsTotal,
+			Field:   "payment.goods_total",
+			Message: fmt.Sprintf("равно %d, а сумма items[].total_price — %d", order.Payment.GoodsTotal, sum),
+		}}
+	}
+	return nil
+}
+
+// ItemTotalPrice возвращает цену товара со скидкой: sale — скидка в процентах,
+// копейки отбрасываются.
+func ItemTotalPrice(price, sale int) int {
+	return price * (100 - sale) / 100
+}
+
+// checkItemTotalPrice: total_price каждого товара равен цене со скидкой.
+func checkItemTotalPrice(order *model.Order) []Violation {
+	var violations []Violation
+	for i, item := range order.Items {
+		if item.Sale > 100 {
+			violations = append(violations, Violation{
+				Rule:    RuleItemTotalPrice,
+				Field:   fmt.Sprintf("items[%d].sale", i),
+				Message: fmt.Sprintf("скидка %d%% больше 100%%", item.Sale),
+			})
+			continue
+		}
+		if want := ItemTotalPrice(item.Price, item.Sale); item.TotalPrice != want {
+			violations = append(violations, Violation{
+				Rule:    RuleItemTotalPrice,
+				Field:   fmt.Sprintf("items[%d].total_price", i),
+				Message: fmt.Sprintf("равно %d, а цена %d со скидкой %d%% — %d", item.TotalPrice, item.Price, item.Sale, want),
+			})
+		}
+	}
+	return violations
+}
+
+// checkItemTrackNumber: трек-номер каждого товара совпадает с трек-номером заказа.
+func checkItemTrackNumber(order *model.Order) []Violation {
+	var violations []Violation
+	for i, item := range order.Items {
+		if item.TrackNumber != order.TrackNumber {
+			violations = append(violations, Violation{
+				Rule:    RuleItemTrackNumber,
+				Field:   fmt.Sprintf("items[%d].track_number", i),
+				Message: fmt.Sprintf("%q не совпадает с трек-номером заказа %q", item.TrackNumber, order.TrackNumber),
+			})
+		}
+	}
+	return violations
+}
+
+// checkPaymentTransaction: payment.transaction совпадает с order_uid.
+func checkPaymentTransaction(order *model.Order) []Violation {
+	if order.Payment.Transaction != order.OrderUID {
+		return []Violation{{
+			Rule:    RulePaymentTransaction,
+			Field:   "payment.transaction",
+			Message: fmt.Sprintf("%q не совпадает с order_uid %q", order.Payment.Transaction, order.OrderUID),
+		}}
+	}
+	return nil
+}
+
+// checkPaymentAmount: payment.amount равен сумме товаров, доставки и пошлины.
+func checkPaymentAmount(order *model.Order) []Violation {
+	p := order.Payment
+	if want := p.GoodsTotal + p.DeliveryCost + p.CustomFee; p.Amount != want {
+		return []Violation{{
+			Rule:    RulePaymentAmount,
+			Field:   "payment.amount",
+			Message: fmt.Sprintf("равно %d, а goods_total + delivery_cost + custom_fee — %d", p.Amount, want),
+		}}
+	}
+	return nil
+}
+
+// checkCurrency: валюта — действующий код ISO 4217.
+func checkCurrency(order *model.Order) []Violation {
+	if !IsCurrency(order.Payment.Currency) {
+		return []Violation{{
+			Rule:    RuleCurrency,
+			Field:   "payment.currency",
+			Message: fmt.Sprintf("%q не является кодом валюты ISO 4217", order.Payment.Currency),
+		}}
+	}
+	return nil
+}
+
+// dateNotInFuture: date_created не позже текущего времени с учётом skew.
+func dateNotInFuture(now func() time.Time, skew time.Duration) func(order *model.Order) []Violation {
+	return func(order *model.Order) []Violation {
+		if limit := now().Add(skew); order.DateCreated.After(limit) {
+			return []Violation{{
+				Rule:    RuleDateCreated,
+				Field:   "date_created",
+				Message: fmt.Sprintf("%s в будущем", order.DateCreated.Format(time.RFC3339)),
+			}}
+		}
+		return nil
+	}
+}
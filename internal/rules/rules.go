@@ -0,0 +1,122 @@
+package rules
+
+import (
+	"L0_project/internal/model"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Violation — нарушение бизнес-правила заказа.
+type Violation struct {
+	// Rule — имя нарушенного правила.
+	Rule string `json:"rule"`
+	// Field — путь к полю заказа в JSON, например "items[0].total_price".
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s: %s", v.Rule, v.Field, v.Message)
+}
+
+// ViolationError возвращается Engine.Validate, если заказ нарушает хотя бы одно правило.
+type ViolationError struct {
+	Violations []Violation
+}
+
+func (e *ViolationError) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		parts[i] = v.String()
+	}
+	return "нарушены бизнес-правила заказа: " + strings.Join(parts, "; ")
+}
+
+// Rule — бизнес-правило. Check возвращает все найденные нарушения, а не только первое.
+type Rule struct {
+	Name  string
+	Check func(order *model.Order) []Violation
+}
+
+// Engine проверяет заказ набором правил. Правила выполняются после проверки
+// struct-тегов, поэтому могут рассчитывать на заполненные обязательные поля.
+type Engine struct {
+	rules []Rule
+}
+
+// NewEngine создает движок с заданными правилами.
+func NewEngine(rules ...Rule) *Engine {
+	return &Engine{rules: rules}
+}
+
+// Config выбирает встроенные правила для New.
+type Config struct {
+	// Disabled — имена отключённых правил.
+	Disabled []string
+	// Enabled — имена включённых необязательных правил (см. Optional).
+	Enabled []string
+	// MaxClockSkew — допустимое опережение date_created относительно текущего времени.
+	MaxClockSkew time.Duration
+}
+
+// New создает движок со встроенными правилами, кроме отключённых в cfg,
+// и необязательными правилами, включёнными в cfg. Отключение сильнее включения.
+func New(cfg Config) (*Engine, error) {
+	disabled, enabled := nameSet(cfg.Disabled), nameSet(cfg.Enabled)
+	known := make(map[string]bool)
+
+	var rules []Rule
+	for _, rule := range Builtin(cfg.MaxClockSkew) {
+		known[rule.Name] = true
+		if !disabled[rule.Name] {
+			rules = append(rules, rule)
+		}
+	}
+	for _, rule := range Optional() {
+		known[rule.Name] = true
+		if enabled[rule.Name] && !disabled[rule.Name] {
+			rules = append(rules, rule)
+		}
+	}
+	for _, names := range []map[string]bool{disabled, enabled} {
+		for name := range names {
+			if !known[name] {
+				return nil, fmt.Errorf("неизвестное бизнес-правило %q", name)
+			}
+		}
+	}
+	return NewEngine(rules...), nil
+}
+
+// nameSet возвращает множество непустых имён правил без пробелов по краям.
+func nameSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			set[name] = true
+		}
+	}
+	return set
+}
+
+// Validate проверяет заказ всеми правилами и возвращает *ViolationError со всеми нарушениями.
+func (e *Engine) Validate(order *model.Order) error {
+	var violations []Violation
+	for _, rule := range e.rules {
+		violations = append(violations, rule.Check(order)...)
+	}
+	if len(violations) > 0 {
+		return &ViolationError{Violations: violations}
+	}
+	return nil
+}
+
+// Names возвращает имена включённых правил.
+func (e *Engine) Names() []string {
+	names := make([]string, len(e.rules))
+	for i, rule := range e.rules {
+		names[i] = rule.Name
+	}
+	return names
+}
@@ -0,0 +1,155 @@
+package rules
+
+import (
+	"L0_project/internal/model"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// validOrder возвращает заказ, который проходит все встроенные правила, включая необязательные.
+func validOrder() *model.Order {
+	return &model.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Payment: model.Payment{
+			Transaction: "b563feb7b2b84b6test", Currency: "USD",
+			Amount: 1817, DeliveryCost: 1500, GoodsTotal: 317,
+		},
+		Items: []model.Item{
+			{TrackNumber: "WBILMTESTTRACK", Price: 453, Sale: 30, TotalPrice: 317},
+		},
+		DateCreated: now.Add(-time.Hour),
+	}
+}
+
+func TestBuiltinRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   string
+		mutate func(o *model.Order)
+		fields []string
+	}{
+		{"goods_total совпадает", RuleGoodsTotal, func(o *model.Order) {}, nil},
+		{"goods_total не совпадает", RuleGoodsTotal, func(o *model.Order) { o.Payment.GoodsTotal = 316 }, []string{"payment.goods_total"}},
+		{"goods_total без товаров", RuleGoodsTotal, func(o *model.Order) { o.Items = nil }, []string{"payment.goods_total"}},
+
+		{"total_price со скидкой", RuleItemTotalPrice, func(o *model.Order) {}, nil},
+		{"total_price без скидки", RuleItemTotalPrice, func(o *model.Order) { o.Items[0].Sale, o.Items[0].TotalPrice = 0, 453 }, nil},
+		{"total_price при скидке 100%", RuleItemTotalPrice, func(o *model.Order) { o.Items[0].Sale, o.Items[0].TotalPrice = 100, 0 }, nil},
+		{"total_price не совпадает", RuleItemTotalPrice, func(o *model.Order) { o.Items[0].TotalPrice = 318 }, []string{"items[0].total_price"}},
+		{"скидка больше 100%", RuleItemTotalPrice, func(o *model.Order) { o.Items[0].Sale = 101 }, []string{"items[0].sale"}},
+
+		{"трек-номер товара совпадает", RuleItemTrackNumber, func(o *model.Order) {}, nil},
+		{"трек-номер товара не совпадает", RuleItemTrackNumber, func(o *model.Order) {
+			o.Items = append(o.Items, model.Item{TrackNumber: "OTHER"})
+		}, []string{"items[1].track_number"}},
+
+		{"transaction совпадает", RulePaymentTransaction, func(o *model.Order) {}, nil},
+		{"transaction не совпадает", RulePaymentTransaction, func(o *model.Order) { o.Payment.Transaction = "other" }, []string{"payment.transaction"}},
+
+		{"amount совпадает", RulePaymentAmount, func(o *model.Order) {}, nil},
+		{"amount с пошлиной", RulePaymentAmount, func(o *model.Order) { o.Payment.CustomFee, o.Payment.Amount = 100, 1917 }, nil},
+		{"amount не совпадает", RulePaymentAmount, func(o *model.Order) { o.Payment.Amount = 1816 }, []string{"payment.amount"}},
+
+		{"валюта ISO 4217", RuleCurrency, func(o *model.Order) {}, nil},
+		{"валюта без кода страны", RuleCurrency, func(o *model.Order) { o.Payment.Currency = "XAU" }, nil},
+		{"валюта в нижнем регистре", RuleCurrency, func(o *model.Order) { o.Payment.Currency = "usd" }, []string{"payment.currency"}},
+		{"валюта пустая", RuleCurrency, func(o *model.Order) { o.Payment.Currency = "" }, []string{"payment.currency"}},
+		{"валюта из двух букв", RuleCurrency, func(o *model.Order) { o.Payment.Currency = "US" }, []string{"payment.currency"}},
+		{"валюта с пробелом", RuleCurrency, func(o *model.Order) { o.Payment.Currency = "USD " }, []string{"payment.currency"}},
+		{"выведенная из обращения валюта", RuleCurrency, func(o *model.Order) { o.Payment.Currency = "RUR" }, []string{"payment.currency"}},
+
+		{"date_created в прошлом", RuleDateCreated, func(o *model.Order) {}, nil},
+		{"date_created в пределах допуска", RuleDateCreated, func(o *model.Order) { o.DateCreated = now.Add(time.Minute) }, nil},
+		{"date_created на границе допуска", RuleDateCreated, func(o *model.Order) { o.DateCreated = now.Add(5 * time.Minute) }, nil},
+		{"date_created в будущем", RuleDateCreated, func(o *model.Order) { o.DateCreated = now.Add(6 * time.Minute) }, []string{"date_created"}},
+	}
+
+	checks := map[string]func(*model.Order) []Violation{
+		RuleDateCreated: dateNotInFuture(func() time.Time { return now }, 5*time.Minute),
+	}
+	for _, rule := range append(Builtin(0), Optional()...) {
+		if _, ok := checks[rule.Name]; !ok {
+			checks[rule.Name] = rule.Check
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := validOrder()
+			tt.mutate(order)
+
+			var fields []string
+			for _, v := range checks[tt.rule](order) {
+				if v.Rule != tt.rule {
+					t.Errorf("нарушение %v относится к правилу %q", v, v.Rule)
+				}
+				fields = append(fields, v.Field)
+			}
+			if !slices.Equal(fields, tt.fields) {
+				t.Errorf("нарушения в полях %v, ожидалось %v", fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	defaults := []string{RuleGoodsTotal, RuleItemTotalPrice, RuleItemTrackNumber, RulePaymentTransaction, RuleCurrency, RuleDateCreated}
+	tests := []struct {
+		name    string
+		cfg     Config
+		want    []string
+		wantErr bool
+	}{
+		{"по умолчанию", Config{}, defaults, false},
+		{"отключение", Config{Disabled: []string{RuleCurrency, " " + RuleDateCreated}}, defaults[:4], false},
+		{"включение необязательного", Config{Enabled: []string{RulePaymentAmount}}, append(slices.Clone(defaults), RulePaymentAmount), false},
+		{"отключение сильнее включения", Config{Enabled: []string{RulePaymentAmount}, Disabled: []string{RulePaymentAmount}}, defaults, false},
+		{"включение правила по умолчанию", Config{Enabled: []string{RuleCurrency}}, defaults, false},
+		{"пустые имена", Config{Disabled: []string{""}, Enabled: []string{" "}}, defaults, false},
+		{"неизвестное отключённое", Config{Disabled: []string{"nope"}}, nil, true},
+		{"неизвестное включённое", Config{Enabled: []string{"nope"}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := New(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New: ошибка %v, ожидалась: %v", err, tt.wantErr)
+			}
+			if err == nil && !slices.Equal(e.Names(), tt.want) {
+				t.Errorf("Names() = %v, ожидалось %v", e.Names(), tt.want)
+			}
+		})
+	}
+}
+
+func TestEngineValidateReportsAllViolations(t *testing.T) {
+	e, err := New(Config{Enabled: []string{RulePaymentAmount}, MaxClockSkew: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Validate(validOrder()); err != nil {
+		t.Fatalf("корректный заказ отклонён: %v", err)
+	}
+
+	order := validOrder()
+	order.Payment.Currency = "usd"
+	order.Payment.Amount = 0
+	err = e.Validate(order)
+
+	var verr *ViolationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Validate = %v, ожидалась *ViolationError", err)
+	}
+	var rules []string
+	for _, v := range verr.Violations {
+		rules = append(rules, v.Rule)
+	}
+	if want := []string{RuleCurrency, RulePaymentAmount}; !slices.Equal(rules, want) {
+		t.Errorf("нарушены правила %v, ожидалось %v", rules, want)
+	}
+}
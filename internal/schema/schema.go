@@ -0,0 +1,78 @@
+package schema
+
+import (
+	"L0_project/internal/model"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Версии схемы сообщения с заказом.
+const (
+	// V1 — исходный формат: JSON в точности повторяет model.Order. Сообщения без
+	// версии считаются V1, так их публиковали продюсеры до появления версий.
+	V1 = 1
+	// V2 — payment.payment_dt передаётся как время в RFC 3339, а не Unix-секунды.
+	V2 = 2
+
+	// Current — версия, в которой публикует Encode.
+	Current = V2
+)
+
+// ErrUnsupportedVersion возвращается для версии, для которой нет декодера.
+var ErrUnsupportedVersion = errors.New("неподдерживаемая версия схемы заказа")
+
+// Decoder разбирает сообщение своей версии и приводит его к текущей model.Order.
+type Decoder func(data []byte) (*model.Order, error)
+
+// Registry хранит декодеры по версиям схемы. Consumer разбирает любую
+// зарегистрированную версию, поэтому продюсер можно обновлять независимо от него.
+type Registry struct {
+	decoders map[int]Decoder
+}
+
+// NewRegistry создает пустой реестр.
+func NewRegistry() *Registry {
+	return &Registry{decoders: make(map[int]Decoder)}
+}
+
+// Default возвращает реестр со всеми поддерживаемыми версиями.
+func Default() *Registry {
+	r := NewRegistry()
+	r.Register(V1, decodeV1)
+	r.Register(V2, decodeV2)
+	return r
+}
+
+// Register добавляет или заменяет декодер версии.
+func (r *Registry) Register(version int, d Decoder) {
+	r.decoders[version] = d
+}
+
+// Decode разбирает сообщение версии version.
+func (r *Registry) Decode(version int, data []byte) (*model.Order, error) {
+	d, ok := r.decoders[version]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+	order, err := d(data)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось разобрать заказ версии %d: %w", version, err)
+	}
+	return order, nil
+}
+
+// Versions возвращает зарегистрированные версии по возрастанию.
+func (r *Registry) Versions() []int {
+	versions := make([]int, 0, len(r.decoders))
+	for v := range r.decoders {
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// Encode сериализует заказ в текущей версии схемы.
+func Encode(order *model.Order) ([]byte, error) {
+	return encodeV2(order)
+}
@@ -0,0 +1,126 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// update перезаписывает golden-файлы: go test ./internal/schema -update.
+var update = flag.Bool("update", false, "перезаписать golden-файлы в testdata")
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecodeGolden(t *testing.T) {
+	registry := Default()
+	for _, version := range registry.Versions() {
+		name := "v" + strconv.Itoa(version)
+		t.Run(name, func(t *testing.T) {
+			input := readTestdata(t, name+".json")
+			order, err := registry.Decode(version, input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.MarshalIndent(order, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			golden := filepath.Join("testdata", name+".golden.json")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("заказ версии %d не совпадает с %s:\n%s", version, golden, got)
+			}
+		})
+	}
+}
+
+func TestDecodeVersionsAgree(t *testing.T) {
+	registry := Default()
+	v1, err := registry.Decode(V1, readTestdata(t, "v1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := registry.Decode(V2, readTestdata(t, "v2.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := json.Marshal(v1)
+	b, _ := json.Marshal(v2)
+	if !bytes.Equal(a, b) {
+		t.Errorf("версии разобраны в разные заказы:\nV1: %s\nV2: %s", a, b)
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	registry := Default()
+	want, err := registry.Decode(V1, readTestdata(t, "v1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := Encode(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := registry.Decode(Current, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := json.Marshal(want)
+	b, _ := json.Marshal(got)
+	if !bytes.Equal(a, b) {
+		t.Errorf("заказ изменился после Encode и Decode:\nбыло:  %s\nстало: %s", a, b)
+	}
+}
+
+func TestDecodeUnsupportedVersion(t *testing.T) {
+	for _, version := range []int{0, 3, -1} {
+		_, err := Default().Decode(version, readTestdata(t, "v1.json"))
+		if !errors.Is(err, ErrUnsupportedVersion) {
+			t.Errorf("Decode(%d): ошибка %v, ожидалась ErrUnsupportedVersion", version, err)
+		}
+	}
+}
+
+func TestDecodeV2RejectsMalformedPaymentDt(t *testing.T) {
+	valid := string(readTestdata(t, "v2.json"))
+	for _, dt := range []string{
+		`"2021-11-26 06:22:07"`,
+		`"26.11.2021"`,
+		`"2021-11-26T06:22:07"`,
+		`""`,
+		`1637907727`,
+	} {
+		data := strings.Replace(valid, `"2021-11-26T06:22:07Z"`, dt, 1)
+		if data == valid {
+			t.Fatal("в v2.json нет payment_dt для замены")
+		}
+		if _, err := Default().Decode(V2, []byte(data)); err == nil {
+			t.Errorf("payment_dt %s принят", dt)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package schema
+
+import (
+	"L0_project/internal/model"
+	"encoding/json"
+)
+
+// decodeV1 разбирает исходный формат: он совпадает с JSON-представлением model.Order.
+func decodeV1(data []byte) (*model.Order, error) {
+	var order model.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
@@ -0,0 +1,40 @@
+package schema
+
+import (
+	"L0_project/internal/model"
+	"encoding/json"
+	"time"
+)
+
+// orderV2 — формат V2. Отличается от V1 только типом payment.payment_dt.
+type orderV2 struct {
+	model.Order
+	Payment paymentV2 `json:"payment"`
+}
+
+type paymentV2 struct {
+	model.Payment
+	PaymentDt time.Time `json:"payment_dt"`
+}
+
+func decodeV2(data []byte) (*model.Order, error) {
+	var v orderV2
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	order := v.Order
+	order.Payment = v.Payment.Payment
+	order.Payment.PaymentDt = v.Payment.PaymentDt.Unix()
+	return &order, nil
+}
+
+func encodeV2(order *model.Order) ([]byte, error) {
+	v := orderV2{
+		Order: *order,
+		Payment: paymentV2{
+			Payment:   order.Payment,
+			PaymentDt: time.Unix(order.Payment.PaymentDt, 0).UTC(),
+		},
+	}
+	return json.Marshal(&v)
+}
@@ -1,4 +1,4 @@
--- Создано из internal/database/schema.sql
+-- Исходная схема: доставки, платежи, заказы и товары.
 CREATE TABLE IF NOT EXISTS deliveries (
     id SERIAL PRIMARY KEY,
     name VARCHAR(255) NOT NULL,
@@ -0,0 +1,6 @@
+-- Версия и хэш содержимого заказа для идемпотентного сохранения.
+-- У существующих заказов хэш пустой, поэтому первая повторная доставка обновит их до версии 2.
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS payload_hash TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
@@ -0,0 +1,2 @@
+-- Индекс для постраничной выдачи заказов по (date_created, order_uid).
+CREATE INDEX IF NOT EXISTS orders_date_created_uid_idx ON orders (date_created DESC, order_uid DESC);
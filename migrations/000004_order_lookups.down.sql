@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS orders_payment_id_idx;
+DROP INDEX IF EXISTS orders_customer_date_created_idx;
@@ -0,0 +1,4 @@
+-- Индексы для поиска заказов без order_uid.
+-- orders.track_number и payments.transaction уже проиндексированы ограничениями UNIQUE.
+CREATE INDEX IF NOT EXISTS orders_customer_date_created_idx ON orders (customer_id, date_created DESC, order_uid DESC);
+CREATE INDEX IF NOT EXISTS orders_payment_id_idx ON orders (payment_id);
@@ -0,0 +1,13 @@
+-- Ключи идемпотентности POST /api/orders: повтор запроса с тем же ключом
+-- возвращает результат первого сохранения, а не сохраняет заказ заново.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key VARCHAR(255) PRIMARY KEY,
+    order_uid VARCHAR(255) NOT NULL,
+    -- payload_hash — хэш содержимого заказа из первого запроса (см. orders.payload_hash).
+    payload_hash TEXT NOT NULL,
+    result SMALLINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Для удаления просроченных ключей.
+CREATE INDEX IF NOT EXISTS idempotency_keys_created_at_idx ON idempotency_keys (created_at);
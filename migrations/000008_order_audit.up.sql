@@ -0,0 +1,15 @@
+-- Журнал изменений заказа через API: кто, когда и что изменил.
+-- before и after содержат только изменившиеся поля заказа.
+CREATE TABLE IF NOT EXISTS order_audit (
+    id BIGSERIAL PRIMARY KEY,
+    order_uid VARCHAR(255) NOT NULL REFERENCES orders(order_uid) ON DELETE CASCADE,
+    version INT NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    before JSONB NOT NULL,
+    after JSONB NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS order_audit_order_uid_idx ON order_audit (order_uid, id);
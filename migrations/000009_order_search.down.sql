@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS orders_delivery_id_idx;
+
+ALTER TABLE items
+    DROP COLUMN IF EXISTS search_vector,
+    DROP COLUMN IF EXISTS search_text;
+
+ALTER TABLE deliveries
+    DROP COLUMN IF EXISTS search_vector,
+    DROP COLUMN IF EXISTS search_text;
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS search_vector,
+    DROP COLUMN IF EXISTS search_text;
+
+-- Расширение pg_trgm не удаляется: его могут использовать другие объекты базы.
@@ -0,0 +1,41 @@
+-- Полнотекстовый поиск по заказам, доставкам и товарам.
+-- search_text — текст для поиска с опечатками (pg_trgm) и для подсветки,
+-- search_vector — его tsvector с весами: A — имена и идентификаторы, B — адрес.
+-- Конфигурация simple: в данных имена, адреса и бренды, стемминг им только мешает.
+-- Добавление STORED-колонок переписывает таблицы, на большой базе миграцию стоит
+-- выполнять в окно обслуживания.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS search_text TEXT GENERATED ALWAYS AS (
+        order_uid || ' ' || track_number || ' ' || customer_id
+    ) STORED,
+    ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', order_uid || ' ' || track_number || ' ' || customer_id), 'A')
+    ) STORED;
+
+ALTER TABLE deliveries
+    ADD COLUMN IF NOT EXISTS search_text TEXT GENERATED ALWAYS AS (
+        name || ' ' || email || ' ' || phone || ' ' || zip || ' ' || city || ' ' || address || ' ' || region
+    ) STORED,
+    ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', name || ' ' || email || ' ' || phone), 'A') ||
+        setweight(to_tsvector('simple', zip || ' ' || city || ' ' || address || ' ' || region), 'B')
+    ) STORED;
+
+ALTER TABLE items
+    ADD COLUMN IF NOT EXISTS search_text TEXT GENERATED ALWAYS AS (
+        name || ' ' || brand
+    ) STORED,
+    ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', name || ' ' || brand), 'A')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS orders_search_vector_idx ON orders USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS orders_search_text_trgm_idx ON orders USING GIN (search_text gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS deliveries_search_vector_idx ON deliveries USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS deliveries_search_text_trgm_idx ON deliveries USING GIN (search_text gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS items_search_vector_idx ON items USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS items_search_text_trgm_idx ON items USING GIN (search_text gin_trgm_ops);
+-- Поиск по доставке возвращает заказ через orders.delivery_id.
+CREATE INDEX IF NOT EXISTS orders_delivery_id_idx ON orders (delivery_id);
@@ -0,0 +1,5 @@
+-- Время сообщения Kafka, из которого сохранено текущее содержимое заказа.
+-- Сообщение старше сохранённого не перезаписывает заказ. NULL — время неизвестно
+-- (заказ из API или сохранён до миграции), такие заказы проверку не проходят.
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS source_at TIMESTAMPTZ;
@@ -0,0 +1,18 @@
+-- Хэш последнего принятого содержимого заказа (из Kafka или POST /api/orders)
+-- без изменений через API. Повторная доставка этого содержимого ничего не меняет,
+-- а другое содержимое для заказа, изменённого через API (payload_hash отличается
+-- от ingested_hash), отклоняется как конфликт.
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS ingested_hash TEXT NOT NULL DEFAULT '';
+
+-- Для заказов без изменений содержимого принятое содержимое совпадает с текущим.
+-- Исходный хэш изменённых заказов неизвестен: он остаётся пустым, и любое
+-- содержимое из Kafka для них — конфликт.
+UPDATE orders o
+SET ingested_hash = o.payload_hash
+WHERE o.ingested_hash = ''
+  AND NOT EXISTS (
+      SELECT 1 FROM order_audit a
+      WHERE a.order_uid = o.order_uid
+        AND a.action IN ('amend_delivery', 'amend_item', 'remove_item')
+  );
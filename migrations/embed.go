@@ -0,0 +1,10 @@
+// Package migrations встраивает SQL-миграции в бинарный файл сервиса
+// (см. database.Migrator и подкоманду migrate).
+package migrations
+
+import "embed"
+
+// FS содержит файлы миграций {version}_{name}.up.sql и {version}_{name}.down.sql.
+//
+//go:embed *.sql
+var FS embed.FS
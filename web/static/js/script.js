@@ -6,6 +6,9 @@ document.addEventListener('DOMContentLoaded', () => {
    const loader = document.getElementById('loader');
    const recentOrdersContainer = document.getElementById('recentOrdersContainer');
    const recentTitle = document.getElementById('recent-title');
+   const searchQueryInput = document.getElementById('searchQueryInput');
+   const fullTextSearchButton = document.getElementById('fullTextSearchButton');
+   const searchResultsContainer = document.getElementById('searchResultsContainer');
 
    const showLoader = () => loader.classList.remove('hidden');
    const hideLoader = () => loader.classList.add('hidden');
@@ -14,9 +17,30 @@ document.addEventListener('DOMContentLoaded', () => {
        errorContainer.classList.remove('hidden');
    };
    const hideError = () => errorContainer.classList.add('hidden');
+
+   // Тексты ошибок по полю code ответа application/problem+json.
+   const errorMessages = {
+       order_not_found: 'Заказ не найден',
+       invalid_request: 'Некорректный запрос',
+       not_acceptable: 'Формат ответа не поддерживается',
+       service_unavailable: 'Сервис временно недоступен, попробуйте позже',
+       internal_error: 'Внутренняя ошибка сервиса',
+   };
+
+   async function problemMessage(response) {
+       const contentType = response.headers.get('Content-Type') || '';
+       if (contentType.startsWith('application/problem+json')) {
+           const problem = await response.json();
+           return errorMessages[problem.code] || problem.detail || problem.title;
+       }
+       return `Ошибка сервиса (статус ${response.status})`;
+   }
+
    const clearResults = () => {
        resultContainer.innerHTML = '';
        resultContainer.classList.add('hidden');
+       searchResultsContainer.innerHTML = '';
+       searchResultsContainer.classList.add('hidden');
    }
 
    async function fetchOrder() {
@@ -31,10 +55,9 @@ document.addEventListener('DOMContentLoaded', () => {
        recentTitle.classList.add('hidden');
 
        try {
-           const response = await fetch(`/api/order/${orderUid}`);
+           const response = await fetch(`/api/order/${encodeURIComponent(orderUid)}`);
            if (!response.ok) {
-               const errorText = await response.text();
-               throw new Error(`Заказ не найден (Статус: ${response.status}). ${errorText}`);
+               throw new Error(await problemMessage(response));
            }
            const data = await response.json();
            displayOrder(data);
@@ -148,7 +171,7 @@ document.addEventListener('DOMContentLoaded', () => {
    async function fetchAndDisplayRecentOrders() {
        try {
            const response = await fetch('/api/orders/recent');
-           if (!response.ok) throw new Error('Ошибка загрузки');
+           if (!response.ok) throw new Error(await problemMessage(response));
            const orders = await response.json();
            
            if (orders && orders.length > 0) {
@@ -160,8 +183,110 @@ document.addEventListener('DOMContentLoaded', () => {
        }
    }
 
+   // Где найдено совпадение (поле source в ответе /api/search).
+   const searchSourceLabels = {
+       order: 'Заказ',
+       delivery: 'Доставка',
+       item: 'Товар',
+   };
+
+   // Фрагмент с подсветкой строится из текста и элементов <mark>, без innerHTML:
+   // данные заказа не интерпретируются как разметка.
+   function highlightedFragment(fragment) {
+       const span = document.createElement('span');
+       fragment.split(/<mark>([\s\S]*?)<\/mark>/).forEach((part, i) => {
+           if (i % 2 === 1) {
+               const mark = document.createElement('mark');
+               mark.textContent = part;
+               span.appendChild(mark);
+           } else {
+               span.append(part);
+           }
+       });
+       return span;
+   }
+
+   function displaySearchResults(results) {
+       searchResultsContainer.innerHTML = '';
+       if (results.length === 0) {
+           const empty = document.createElement('p');
+           empty.className = 'search-empty';
+           empty.textContent = 'Ничего не найдено';
+           searchResultsContainer.appendChild(empty);
+       }
+
+       results.forEach(({ order, highlights }) => {
+           const card = document.createElement('div');
+           card.className = 'card recent-order-card';
+           const grid = document.createElement('div');
+           grid.className = 'grid';
+           [['UID', order.order_uid], ['Получатель', order.delivery.name], ['Дата', new Date(order.date_created).toLocaleString()]].forEach(([label, value]) => {
+               const item = document.createElement('div');
+               item.className = 'grid-item';
+               const strong = document.createElement('strong');
+               strong.textContent = `${label}: `;
+               item.append(strong, value);
+               grid.appendChild(item);
+           });
+           card.appendChild(grid);
+
+           highlights.forEach(({ source, fragment }) => {
+               const line = document.createElement('div');
+               line.className = 'search-highlight';
+               const strong = document.createElement('strong');
+               strong.textContent = `${searchSourceLabels[source] || source}: `;
+               line.append(strong, highlightedFragment(fragment));
+               card.appendChild(line);
+           });
+
+           card.addEventListener('click', () => {
+               orderUidInput.value = order.order_uid;
+               fetchOrder();
+               window.scrollTo(0, 0);
+           });
+           searchResultsContainer.appendChild(card);
+       });
+       searchResultsContainer.classList.remove('hidden');
+   }
+
+   async function searchOrders() {
+       const query = searchQueryInput.value.trim();
+       if (query.length < 2) return;
+
+       showLoader();
+       fullTextSearchButton.disabled = true;
+       hideError();
+       clearResults();
+       recentOrdersContainer.classList.add('hidden');
+       recentTitle.classList.add('hidden');
+
+       try {
+           const response = await fetch(`/api/search?q=${encodeURIComponent(query)}`);
+           if (!response.ok) {
+               throw new Error(await problemMessage(response));
+           }
+           const data = await response.json();
+           displaySearchResults(data.results);
+       } catch (error) {
+           showError(error.message);
+       } finally {
+           hideLoader();
+           fullTextSearchButton.disabled = searchQueryInput.value.trim().length < 2;
+       }
+   }
+
    fetchAndDisplayRecentOrders();
 
+   fullTextSearchButton.addEventListener('click', searchOrders);
+   searchQueryInput.addEventListener('keypress', (event) => {
+       if (event.key === 'Enter') {
+           searchOrders();
+       }
+   });
+   searchQueryInput.addEventListener('input', () => {
+       fullTextSearchButton.disabled = searchQueryInput.value.trim().length < 2;
+   });
+
    searchButton.addEventListener('click', fetchOrder);
    orderUidInput.addEventListener('keypress', (event) => {
        if (event.key === 'Enter') {